@@ -0,0 +1,114 @@
+package convert
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Container is the file format of the converted video.
+type Container string
+
+const (
+	ContainerMP4 Container = "mp4"
+	ContainerMKV Container = "mkv"
+	ContainerMOV Container = "mov"
+	ContainerAVI Container = "avi"
+)
+
+// AudioCodec is the codec used to encode the audio tracks.
+type AudioCodec string
+
+const (
+	AudioAAC  AudioCodec = "AAC"
+	AudioOpus AudioCodec = "Opus"
+	AudioPCM  AudioCodec = "PCM"
+	AudioFLAC AudioCodec = "FLAC"
+)
+
+// AudioCodecs lists all supported audio codecs in the order shown to the user.
+var AudioCodecs = []AudioCodec{AudioAAC, AudioOpus, AudioPCM, AudioFLAC}
+
+// containerAudio holds the audio codecs each container can carry, the
+// first codec is the default of the container.
+var containerAudio = map[Container][]AudioCodec{
+	ContainerMP4: {AudioAAC, AudioOpus, AudioFLAC},
+	ContainerMKV: {AudioAAC, AudioOpus, AudioPCM, AudioFLAC},
+	ContainerMOV: {AudioAAC, AudioPCM},
+	ContainerAVI: {AudioPCM},
+}
+
+// AudioCodecsFor returns the audio codecs the container supports.
+func AudioCodecsFor(c Container) []AudioCodec {
+	return containerAudio[c]
+}
+
+// SupportedIn returns true if the codec can be muxed into the container.
+func (a AudioCodec) SupportedIn(c Container) bool {
+	return slices.Contains(containerAudio[c], a)
+}
+
+// Lossless returns true for codecs without a bit rate setting.
+func (a AudioCodec) Lossless() bool {
+	return a == AudioPCM || a == AudioFLAC
+}
+
+// Downmix reduces the number of audio channels.
+type Downmix string
+
+const (
+	DownmixNone   Downmix = ""       // keep the channels of the source
+	DownmixStereo Downmix = "stereo" // 5.1 is mixed down with ITU coefficients
+	DownmixMono   Downmix = "mono"
+)
+
+// Channels returns the number of channels after the downmix, 0 keeps the source.
+func (d Downmix) Channels() int {
+	switch d {
+	case DownmixStereo:
+		return 2
+	case DownmixMono:
+		return 1
+	}
+	return 0
+}
+
+// AudioSettings configures the audio conversion of a project.
+type AudioSettings struct {
+	Codec      AudioCodec // codec of the output tracks
+	Bitrate    int        // bit rate in kbit/s for lossy codecs
+	Downmix    Downmix    // channel reduction
+	SampleRate int        // resample to this rate in Hz, 0 keeps the source rate
+	DelayMs    int        // delay added to all audio tracks in milliseconds
+}
+
+// NewAudioSettings returns the default audio settings, AAC stereo with 192 kbit/s.
+func NewAudioSettings() AudioSettings {
+	return AudioSettings{
+		Codec:   AudioAAC,
+		Bitrate: 192,
+		Downmix: DownmixStereo,
+	}
+}
+
+// Validate checks the settings against the output container.
+func (a AudioSettings) Validate(c Container) error {
+	if !a.Codec.SupportedIn(c) {
+		return fmt.Errorf("audio codec %s is not supported in %s, use one of %v", a.Codec, c, AudioCodecsFor(c))
+	}
+	if !a.Codec.Lossless() && a.Bitrate <= 0 {
+		return fmt.Errorf("audio bit rate missing for %s", a.Codec)
+	}
+	if a.SampleRate < 0 {
+		return fmt.Errorf("invalid sample rate %d", a.SampleRate)
+	}
+	return nil
+}
+
+// ForContainer returns the settings with the codec replaced by the default
+// codec of the container if the container does not support it.
+func (a AudioSettings) ForContainer(c Container) AudioSettings {
+	if !a.Codec.SupportedIn(c) && len(containerAudio[c]) > 0 {
+		a.Codec = containerAudio[c][0]
+	}
+	return a
+}
@@ -0,0 +1,216 @@
+package convert
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// audioJob returns a job of a source with a German stereo track and an
+// English 5.1 default track starting 40 ms after the video.
+func audioJob() *Job {
+	return &Job{
+		Source: &util.MediaInfo{
+			Name:  "clip.mkv",
+			Video: []util.VideoStream{{Index: 0, StartTime: 20 * time.Millisecond}},
+			Audio: []util.AudioStream{
+				{Index: 1, Channels: 2, Language: "ger", StartTime: 20 * time.Millisecond},
+				{Index: 2, Channels: 6, Language: "eng", StartTime: 60 * time.Millisecond, Default: true},
+			},
+		},
+		Container: ContainerMKV,
+		Audio:     NewAudioSettings(),
+	}
+}
+
+func TestSelectedAudio(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []int
+		audio  []util.AudioStream
+		want   []int
+	}{
+		{"default track", nil, nil, []int{2}},
+		{"first track without default", nil, []util.AudioStream{{Index: 3}, {Index: 4}}, []int{3}},
+		{"selection in the order of the user", []int{2, 1}, nil, []int{2, 1}},
+		{"unknown tracks", []int{7}, nil, []int{2}},
+		{"no audio", nil, []util.AudioStream{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := audioJob()
+			j.AudioTracks = tt.tracks
+			if tt.audio != nil {
+				j.Source.Audio = tt.audio
+			}
+			var got []int
+			for _, a := range j.SelectedAudio() {
+				got = append(got, a.Index)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SelectedAudio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioOffset(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		delayMs int
+		track   int
+		want    time.Duration
+	}{
+		{"in sync", 0, 0, 0, 0},
+		{"later start", 0, 0, 1, 40 * time.Millisecond},
+		{"project delay", 100 * time.Millisecond, 0, 0, 100 * time.Millisecond},
+		{"file correction", 0, -30, 1, 10 * time.Millisecond},
+		{"both", -200 * time.Millisecond, 50, 1, -110 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := audioJob()
+			j.AudioDelay, j.Audio.DelayMs = tt.delay, tt.delayMs
+			if got := j.AudioOffset(j.Source.Audio[tt.track]); got != tt.want {
+				t.Errorf("AudioOffset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// without video the start time of the track is not corrected
+	j := audioJob()
+	j.Source.Video = nil
+	if got := j.AudioOffset(j.Source.Audio[1]); got != 0 {
+		t.Errorf("AudioOffset() without video = %v, want 0", got)
+	}
+}
+
+func TestAudioArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(j *Job)
+		want   []string
+	}{
+		{"default", nil, []string{"-c:a", "aac", "-b:a", "192k", "-metadata:s:a:0", "language=eng"}},
+		{"no audio", func(j *Job) { j.Source.Audio = nil }, []string{"-an"}},
+		{"opus", func(j *Job) { j.Audio.Codec, j.Audio.Bitrate = AudioOpus, 128 },
+			[]string{"-c:a", "libopus", "-b:a", "128k", "-metadata:s:a:0", "language=eng"}},
+		{"flac without bit rate", func(j *Job) { j.Audio.Codec = AudioFLAC },
+			[]string{"-c:a", "flac", "-metadata:s:a:0", "language=eng"}},
+		{"pcm little endian", func(j *Job) { j.Audio.Codec, j.Container = AudioPCM, ContainerAVI },
+			[]string{"-c:a", "pcm_s16le", "-metadata:s:a:0", "language=eng"}},
+		{"pcm big endian in mov", func(j *Job) { j.Audio.Codec, j.Container = AudioPCM, ContainerMOV },
+			[]string{"-c:a", "pcm_s16be", "-metadata:s:a:0", "language=eng"}},
+		{"unknown codec copied", func(j *Job) { j.Audio.Codec, j.Audio.Bitrate = "AC3", 384 },
+			[]string{"-c:a", "copy", "-b:a", "384k", "-metadata:s:a:0", "language=eng"}},
+		{"languages of all tracks", func(j *Job) { j.AudioTracks = []int{1, 2} },
+			[]string{"-c:a", "aac", "-b:a", "192k", "-metadata:s:a:0", "language=ger", "-metadata:s:a:1", "language=eng"}},
+		{"track without language", func(j *Job) { j.Source.Audio[1].Language = "" },
+			[]string{"-c:a", "aac", "-b:a", "192k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := audioJob()
+			if tt.change != nil {
+				tt.change(j)
+			}
+			if got := audioArgs(j); !slices.Equal(got, tt.want) {
+				t.Errorf("audioArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  AudioSettings
+		container Container
+		wantErr   bool
+	}{
+		{"default in mp4", NewAudioSettings(), ContainerMP4, false},
+		{"aac in avi", NewAudioSettings(), ContainerAVI, true},
+		{"pcm in avi", AudioSettings{Codec: AudioPCM}, ContainerAVI, false},
+		{"pcm in mp4", AudioSettings{Codec: AudioPCM}, ContainerMP4, true},
+		{"flac without bit rate", AudioSettings{Codec: AudioFLAC}, ContainerMKV, false},
+		{"opus without bit rate", AudioSettings{Codec: AudioOpus}, ContainerMKV, true},
+		{"sample rate", AudioSettings{Codec: AudioAAC, Bitrate: 128, SampleRate: 48000}, ContainerMOV, false},
+		{"negative sample rate", AudioSettings{Codec: AudioAAC, Bitrate: 128, SampleRate: -1}, ContainerMOV, true},
+		{"unknown container", NewAudioSettings(), "wmv", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.settings.Validate(tt.container); (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%s) = %v, want error %v", tt.container, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAudioSettingsForContainer(t *testing.T) {
+	tests := []struct {
+		codec     AudioCodec
+		container Container
+		want      AudioCodec
+	}{
+		{AudioAAC, ContainerMP4, AudioAAC},
+		{AudioOpus, ContainerMKV, AudioOpus},
+		{AudioAAC, ContainerAVI, AudioPCM},
+		{AudioPCM, ContainerMP4, AudioAAC},
+		{AudioFLAC, ContainerMOV, AudioAAC},
+		{AudioOpus, "wmv", AudioOpus},
+	}
+	for _, tt := range tests {
+		a := AudioSettings{Codec: tt.codec, Bitrate: 160, DelayMs: 40}
+		got := a.ForContainer(tt.container)
+		if want := (AudioSettings{Codec: tt.want, Bitrate: 160, DelayMs: 40}); got != want {
+			t.Errorf("ForContainer(%s) of %s = %+v, want %+v", tt.container, tt.codec, got, want)
+		}
+	}
+}
+
+func TestDownmixChannels(t *testing.T) {
+	tests := map[Downmix]int{DownmixNone: 0, DownmixStereo: 2, DownmixMono: 1}
+	for d, want := range tests {
+		if got := d.Channels(); got != want {
+			t.Errorf("Downmix(%q).Channels() = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestAudioFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(j *Job)
+		track  int
+		want   string
+	}{
+		{"stereo kept", nil, 0, ""},
+		{"5.1 downmix", nil, 1, "pan=stereo|c0=0.4142*c0+0.2929*c2+0.2929*c4|c1=0.4142*c1+0.2929*c2+0.2929*c5"},
+		{"4.0 front channels", func(j *Job) { j.Source.Audio[1].Channels = 4 }, 1, "pan=stereo|c0=c0|c1=c1"},
+		{"channels kept", func(j *Job) { j.Audio.Downmix = DownmixNone }, 1, ""},
+		{"mono", func(j *Job) { j.Audio.Downmix = DownmixMono }, 0, "aformat=channel_layouts=mono"},
+		{"delay", func(j *Job) { j.Audio.DelayMs = 120 }, 0, "adelay=120:all=1"},
+		{"negative delay", func(j *Job) { j.AudioDelay = -1500 * time.Millisecond }, 0,
+			"atrim=start=1.500,asetpts=PTS-STARTPTS"},
+		{"start time of the stream kept", func(j *Job) { j.Source.Audio[0].StartTime = time.Second }, 0, ""},
+		{"resample", func(j *Job) { j.Audio.SampleRate = 48000 }, 0, "aresample=48000"},
+		{"same sample rate", func(j *Job) { j.Audio.SampleRate, j.Source.Audio[0].SampleRate = 48000, 48000 }, 0, ""},
+		{"chain", func(j *Job) { j.Audio.DelayMs, j.Audio.SampleRate = 40, 44100 }, 1,
+			"adelay=40:all=1,pan=stereo|c0=0.4142*c0+0.2929*c2+0.2929*c4|c1=0.4142*c1+0.2929*c2+0.2929*c5,aresample=44100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := audioJob()
+			if tt.change != nil {
+				tt.change(j)
+			}
+			if got := audioFilter(j, j.Source.Audio[tt.track]); got != tt.want {
+				t.Errorf("audioFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package convert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// downmixFunction mixes 5.1 audio down to stereo with the ITU coefficients
+// L = FL + 0.707*C + 0.707*SL, normalized to avoid clipping.
+const downmixFunction = `function DownmixStereo(clip a) {
+	a  = ConvertAudioToFloat(a)
+	fl = GetChannel(a, 1)
+	fr = GetChannel(a, 2)
+	c  = GetChannel(a, 3)
+	sl = GetChannel(a, 5)
+	sr = GetChannel(a, 6)
+	l  = MixAudio(MixAudio(fl, c, 0.4142, 0.2929), sl, 1.0, 0.2929)
+	r  = MixAudio(MixAudio(fr, c, 0.4142, 0.2929), sr, 1.0, 0.2929)
+	return MergeChannels(l, r)
+}
+`
+
+// avsEscaper escapes the text of an escaped AviSynth+ string e"...".
+var avsEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// avsString returns the text as AviSynth string literal with forward
+// slashes. Text with quotes or control characters is written as escaped
+// string of AviSynth+, other characters are kept as UTF-8.
+func avsString(s string) string {
+	s = filepath.ToSlash(s)
+	if !strings.ContainsAny(s, "\"\n\r\t") {
+		return `"` + s + `"`
+	}
+	return `e"` + avsEscaper.Replace(s) + `"`
+}
+
+// AviSynthScript returns the AviSynth+ script of the job. The script loads
+// the video and the first selected audio track, filters and scales the
+// video, applies delay correction, downmix and resampling to the audio and
+// trims both. Plugins are autoloaded from the plugin directory as well, if
+// it is set. The script is read by VirtualDub2, the paths are translated
+// with the path map of the job.
+func AviSynthScript(j *Job, pluginDir string) string {
+	var b strings.Builder
+	tracks := j.SelectedAudio()
+
+	fmt.Fprintf(&b, "# AviSynth+ script for %s\n\n", j.Source.Name)
+	writePluginDir(&b, j.scriptPath(pluginDir))
+	if needsDownmixFunction(j, tracks) {
+		b.WriteString(downmixFunction + "\n")
+	}
+
+	fmt.Fprintf(&b, "video = LWLibavVideoSource(%s, cachefile=%s)\n",
+		avsString(j.scriptPath(j.Source.FullPath)), avsString(j.scriptPath(j.IndexPath())))
+	for _, line := range j.Filters.AviSynth() {
+		b.WriteString(line + "\n")
+	}
+	if w, h := j.OutputSize(); w > 0 {
+		fmt.Fprintf(&b, "video = Spline36Resize(video, %d, %d)\n", w, h)
+	}
+
+	if len(tracks) == 0 {
+		b.WriteString("clip = video\n")
+	} else {
+		writeAudio(&b, j, tracks[0], j.scriptPath)
+		b.WriteString("clip = AudioDub(video, audio)\n")
+	}
+
+	// Trim cuts video and audio of the clip at frame boundaries
+	if rate, ok := j.ScriptRate(); ok && j.Trimmed() {
+		first := rate.Frames(j.TrimStart)
+		last := int64(0) // 0 is the last frame of the clip
+		if j.TrimEnd > 0 {
+			last = rate.Frames(j.TrimEnd) - 1
+		}
+		fmt.Fprintf(&b, "clip = Trim(clip, %d, %d)\n", first, last)
+	}
+	b.WriteString("clip\n")
+	return b.String()
+}
+
+// AudioTrackScript returns an audio only AviSynth+ script for an additional
+// audio track. The video clip only holds one audio track, further selected
+// tracks are rendered by their own script and muxed by the encoder. The
+// script is only read by the native ffmpeg with a native AviSynth+ and
+// keeps the native paths. Under Wine AviSynth+ is only available to
+// VirtualDub2, VirtualDubBackend.Check rejects additional tracks there.
+func AudioTrackScript(j *Job, a util.AudioStream, pluginDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# AviSynth+ audio script for %s, stream %d\n\n", j.Source.Name, a.Index)
+	writePluginDir(&b, pluginDir)
+	if needsDownmixFunction(j, []util.AudioStream{a}) {
+		b.WriteString(downmixFunction + "\n")
+	}
+	writeAudio(&b, j, a, nativePath)
+	if j.Trimmed() {
+		// AudioTrim takes a negative end as duration
+		end := "0"
+		if j.TrimEnd > 0 {
+			end = "-" + seconds(j.OutputDuration())
+		}
+		fmt.Fprintf(&b, "audio = AudioTrim(audio, %s, %s)\n", seconds(j.TrimStart), end)
+	}
+	b.WriteString("audio\n")
+	return b.String()
+}
+
+// nativePath keeps the path of scripts read by ffmpeg, which runs natively
+// with a native AviSynth+.
+func nativePath(path string) string {
+	return path
+}
+
+// writePluginDir adds the plugin directory to the autoload directories.
+func writePluginDir(b *strings.Builder, pluginDir string) {
+	if pluginDir != "" {
+		fmt.Fprintf(b, "AddAutoloadDir(%s)\n\n", avsString(pluginDir))
+	}
+}
+
+// writeAudio writes the lines loading and processing the audio track into
+// the variable "audio", with the paths translated by path.
+func writeAudio(b *strings.Builder, j *Job, a util.AudioStream, path func(string) string) {
+	fmt.Fprintf(b, "audio = LWLibavAudioSource(%s, stream_index=%d, cachefile=%s)\n",
+		avsString(path(j.Source.FullPath)), a.Index, avsString(path(j.IndexPath())))
+
+	float := false // the downmix and resampling produce float samples
+	if offset := j.AudioOffset(a); offset != 0 {
+		fmt.Fprintf(b, "audio = DelayAudio(audio, %.3f)\n", offset.Seconds())
+	}
+
+	switch j.Audio.Downmix {
+	case DownmixStereo:
+		switch {
+		case a.Channels >= 6:
+			b.WriteString("audio = DownmixStereo(audio)\n")
+			float = true
+		case a.Channels > 2:
+			b.WriteString("audio = GetChannel(audio, 1, 2)\n")
+		}
+	case DownmixMono:
+		if a.Channels != 1 {
+			b.WriteString("audio = ConvertToMono(audio)\n")
+		}
+	}
+
+	if rate := j.Audio.SampleRate; rate > 0 && rate != a.SampleRate {
+		fmt.Fprintf(b, "audio = ResampleAudio(audio, %d)\n", rate)
+		float = true
+	}
+
+	// PCM and FLAC get 16 bit samples instead of float
+	if float && j.Audio.Codec.Lossless() {
+		b.WriteString("audio = ConvertAudioTo16bit(audio)\n")
+	}
+}
+
+// needsDownmixFunction returns true if a 5.1 track is mixed down to stereo.
+func needsDownmixFunction(j *Job, tracks []util.AudioStream) bool {
+	if j.Audio.Downmix != DownmixStereo {
+		return false
+	}
+	for _, a := range tracks {
+		if a.Channels >= 6 {
+			return true
+		}
+	}
+	return false
+}
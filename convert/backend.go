@@ -0,0 +1,158 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend names selectable in the system configuration.
+const (
+	BackendVirtualDub = "virtualdub" // AviSynth+ and VirtualDub2, Windows only
+	BackendFFmpeg     = "ffmpeg"     // ffmpeg only, runs on every platform
+)
+
+// Backends lists the names of all backends.
+var Backends = []string{BackendVirtualDub, BackendFFmpeg}
+
+// Progress is reported while a job runs.
+type Progress struct {
+	Stage   string        // running step like "render", "encode" or "mux"
+	Frame   int64         // frames written
+	Time    time.Duration // position in the output
+	Speed   float64       // encoding speed relative to real time
+	Percent float64       // 0..100 of the stage, -1 if unknown
+}
+
+// Backend encodes a job into the output file.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+	// Check returns an error if the backend can't convert the job.
+	Check(j *Job) error
+	// Run converts the job and reports the progress. Run stops when the
+	// context is cancelled.
+	Run(ctx context.Context, j *Job, report func(Progress)) error
+}
+
+// Tools holds the paths of the external programs and plugins used by the
+// backends.
+type Tools struct {
+	FFmpeg             string  // ffmpeg executable
+	VirtualDub         string  // VirtualDub2 executable
+	VSPipe             string  // vspipe executable of VapourSynth
+	AviSynthPlugins    string  // AviSynth+ plugin directory, empty for the default
+	VapourSynthPlugins string  // VapourSynth plugin directory, empty for the default
+	Wine               Wine    // runs VirtualDub2 on Linux, disabled without launcher
+	PathMappings       PathMap // translation of the paths in the scripts read by VirtualDub2
+}
+
+// NewBackend returns the backend with the name, VirtualDub2 for unknown names.
+func NewBackend(name string, tools Tools) Backend {
+	if name == BackendFFmpeg {
+		return &FFmpegBackend{Tools: tools}
+	}
+	return &VirtualDubBackend{Tools: tools}
+}
+
+// ParseProgress reads the key=value blocks written by "ffmpeg -progress"
+// and reports each block. total is the expected duration of the output to
+// calculate the percentage, 0 if unknown.
+func ParseProgress(r io.Reader, stage string, total time.Duration, report func(Progress)) error {
+	p := Progress{Stage: stage, Percent: -1}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "frame":
+			p.Frame, _ = strconv.ParseInt(value, 10, 64)
+		case "out_time_us", "out_time_ms":
+			// both values are microseconds
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil {
+				p.Time = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			p.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+		case "progress":
+			if total > 0 {
+				p.Percent = min(100, 100*p.Time.Seconds()/total.Seconds())
+			}
+			if value == "end" {
+				p.Percent = 100
+			}
+			if report != nil {
+				report(p)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// runFFmpeg runs ffmpeg with progress output on stdout. The end of the
+// error output is returned with the error, ffmpeg prints the reason there.
+func runFFmpeg(ctx context.Context, ffmpeg string, args []string, stage string, total time.Duration, report func(Progress)) error {
+	_, err := runFFmpegInput(ctx, ffmpeg, args, nil, stage, total, report)
+	return err
+}
+
+// runFFmpegInput runs ffmpeg like runFFmpeg reading the input "-" from stdin
+// and returns the error output, where filters print their results.
+func runFFmpegInput(ctx context.Context, ffmpeg string, args []string, stdin io.Reader, stage string, total time.Duration, report func(Progress)) (string, error) {
+	args = append([]string{"-hide_banner", "-nostats", "-progress", "pipe:1"}, args...)
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("%s: %w", stage, err)
+	}
+	parseErr := ParseProgress(stdout, stage, total, report)
+	if err := cmd.Wait(); err != nil {
+		return stderr.String(), fmt.Errorf("%s: %w: %s", stage, err, lastLines(stderr.String(), 3))
+	}
+	return stderr.String(), parseErr
+}
+
+// lastLines returns the last n lines of the text.
+func lastLines(text string, n int) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " / ")
+}
+
+// extractSidecars writes the sidecar subtitles of the job next to the output.
+func extractSidecars(ctx context.Context, ffmpeg string, j *Job, report func(Progress)) error {
+	for _, s := range j.SidecarSubtitles() {
+		if err := runFFmpeg(ctx, ffmpeg, SidecarArgs(j, s), "subtitles", j.OutputDuration(), report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareDirs creates the work and the output directory of the job.
+func prepareDirs(j *Job) error {
+	if err := os.MkdirAll(j.WorkDir, 0o755); err != nil {
+		return fmt.Errorf("create work directory: %w", err)
+	}
+	if err := os.MkdirAll(j.OutputDir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	return nil
+}
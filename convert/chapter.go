@@ -0,0 +1,208 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// ChapterMode defines where the chapters of the output come from.
+type ChapterMode string
+
+const (
+	ChaptersNone     ChapterMode = ""         // no chapters
+	ChaptersSource   ChapterMode = "source"   // chapters of the source
+	ChaptersInterval ChapterMode = "interval" // a chapter every Interval
+	ChaptersScenes   ChapterMode = "scenes"   // chapters at detected scene cuts
+)
+
+// ChapterSettings configures the chapters of a project.
+type ChapterSettings struct {
+	Mode           ChapterMode
+	Interval       time.Duration // chapter length, minimum distance of scene chapters
+	SceneThreshold float64       // ffmpeg scene score 0..1 detected as cut
+}
+
+// NewChapterSettings returns the default chapter settings, source chapters
+// are kept and generated chapters are 5 minutes apart.
+func NewChapterSettings() ChapterSettings {
+	return ChapterSettings{
+		Mode:           ChaptersSource,
+		Interval:       5 * time.Minute,
+		SceneThreshold: 0.4,
+	}
+}
+
+// Chapters returns the chapters of the output, chapters and cuts of the
+// source are moved to the trimmed output. Scene chapters need the cuts
+// detected with DetectSceneCuts in SceneCuts.
+func (j *Job) Chapters() []util.ChapterMark {
+	length := j.OutputDuration()
+	switch j.Chapter.Mode {
+	case ChaptersSource:
+		return trimChapters(j.Source.Chapters, j.TrimStart, length)
+	case ChaptersInterval:
+		return IntervalChapters(length, j.Chapter.Interval)
+	case ChaptersScenes:
+		var cuts []time.Duration
+		for _, cut := range j.SceneCuts {
+			if cut -= j.TrimStart; cut > 0 && cut < length {
+				cuts = append(cuts, cut)
+			}
+		}
+		return SceneChapters(cuts, length, j.Chapter.Interval)
+	}
+	return nil
+}
+
+// trimChapters moves the chapters by -start and drops the chapters outside
+// of the output.
+func trimChapters(chapters []util.ChapterMark, start, length time.Duration) []util.ChapterMark {
+	var trimmed []util.ChapterMark
+	for _, c := range chapters {
+		c.Start, c.End = max(c.Start-start, 0), min(c.End-start, length)
+		if c.End > c.Start {
+			trimmed = append(trimmed, c)
+		}
+	}
+	return trimmed
+}
+
+// IntervalChapters returns chapters of the same length covering the file.
+func IntervalChapters(length, interval time.Duration) []util.ChapterMark {
+	if interval <= 0 || length <= 0 {
+		return nil
+	}
+	var starts []time.Duration
+	for start := time.Duration(0); start < length; start += interval {
+		starts = append(starts, start)
+	}
+	return chaptersAt(starts, length)
+}
+
+// SceneChapters returns chapters starting at the scene cuts. Cuts closer
+// than minGap to the previous chapter or to the end are dropped.
+func SceneChapters(cuts []time.Duration, length, minGap time.Duration) []util.ChapterMark {
+	if length <= 0 {
+		return nil
+	}
+	starts := []time.Duration{0}
+	for _, cut := range cuts {
+		if cut-starts[len(starts)-1] >= minGap && length-cut >= minGap {
+			starts = append(starts, cut)
+		}
+	}
+	return chaptersAt(starts, length)
+}
+
+// chaptersAt returns numbered chapters from the start times, each chapter
+// ends at the start of the next one.
+func chaptersAt(starts []time.Duration, length time.Duration) []util.ChapterMark {
+	chapters := make([]util.ChapterMark, len(starts))
+	for i, start := range starts {
+		end := length
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		chapters[i] = util.ChapterMark{Start: start, End: end, Title: fmt.Sprintf("Chapter %d", i+1)}
+	}
+	return chapters
+}
+
+// SceneDetectArgs returns the ffmpeg arguments printing the time of every
+// frame with a scene score above the threshold.
+func SceneDetectArgs(j *Job) []string {
+	filter := fmt.Sprintf("select='gt(scene,%.3f)',showinfo", j.Chapter.SceneThreshold)
+	return []string{"-hide_banner", "-nostats", "-i", j.Source.FullPath,
+		"-map", "0:v:0", "-vf", filter, "-an", "-f", "null", "-"}
+}
+
+var ptsTime = regexp.MustCompile(`pts_time:\s*([0-9.]+)`)
+
+// ParseSceneCuts returns the cut times of the showinfo output.
+func ParseSceneCuts(output string) []time.Duration {
+	var cuts []time.Duration
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		if m := ptsTime.FindStringSubmatch(line); m != nil {
+			if cut, err := mediatime.ParseSeconds(m[1]); err == nil {
+				cuts = append(cuts, cut)
+			}
+		}
+	}
+	return cuts
+}
+
+// DetectSceneCuts decodes the video with ffmpeg and returns the scene cuts.
+func DetectSceneCuts(ctx context.Context, ffmpeg string, j *Job) ([]time.Duration, error) {
+	out, err := exec.CommandContext(ctx, ffmpeg, SceneDetectArgs(j)...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("scene detection of %s: %v", j.Source.Name, err)
+	}
+	return ParseSceneCuts(string(out)), nil
+}
+
+// FFMetadata returns the chapters as ffmetadata file muxed by ffmpeg.
+func FFMetadata(chapters []util.ChapterMark) string {
+	escape := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		b.WriteString("\n[CHAPTER]\nTIMEBASE=1/1000\n")
+		fmt.Fprintf(&b, "START=%d\nEND=%d\n", c.Start.Milliseconds(), c.End.Milliseconds())
+		if c.Title != "" {
+			fmt.Fprintf(&b, "title=%s\n", escape.Replace(c.Title))
+		}
+	}
+	return b.String()
+}
+
+// OGMChapters returns the chapters in the simple OGM format read by
+// mkvmerge and most authoring tools.
+func OGMChapters(chapters []util.ChapterMark) string {
+	var b strings.Builder
+	for i, c := range chapters {
+		n := fmt.Sprintf("CHAPTER%02d", i+1)
+		title := c.Title
+		if title == "" {
+			title = "Chapter " + strconv.Itoa(i+1)
+		}
+		fmt.Fprintf(&b, "%s=%s\n%sNAME=%s\n", n, mediatime.FormatDuration(c.Start), n, title)
+	}
+	return b.String()
+}
+
+// chapterMuxArgs returns the input and map of the chapter file. Without a
+// chapter file the chapters ffmpeg copies from the inputs are dropped.
+func chapterMuxArgs(j *Job, chapterFile string, input int) (inputs, maps []string) {
+	if chapterFile == "" || j.Container == ContainerAVI {
+		return nil, []string{"-map_chapters", "-1"}
+	}
+	return []string{"-i", chapterFile}, []string{"-map_chapters", strconv.Itoa(input)}
+}
+
+// writeChapters writes the chapters of the job as ffmetadata and OGM file
+// into the work directory. The paths are empty without chapters.
+func writeChapters(j *Job) (ffmeta, ogm string, err error) {
+	chapters := j.Chapters()
+	if len(chapters) == 0 {
+		return "", "", nil
+	}
+	ffmeta, ogm = j.WorkPath(".ffmeta"), j.WorkPath(".chapters.txt")
+	if err := j.writeWorkFile(ffmeta, FFMetadata(chapters)); err != nil {
+		return "", "", err
+	}
+	if err := j.writeWorkFile(ogm, OGMChapters(chapters)); err != nil {
+		return "", "", err
+	}
+	return ffmeta, ogm, nil
+}
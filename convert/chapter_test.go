@@ -0,0 +1,265 @@
+package convert
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// starts returns the start times of the chapters.
+func starts(chapters []util.ChapterMark) []time.Duration {
+	var s []time.Duration
+	for _, c := range chapters {
+		s = append(s, c.Start)
+	}
+	return s
+}
+
+func TestIntervalChapters(t *testing.T) {
+	tests := []struct {
+		name             string
+		length, interval time.Duration
+		want             []time.Duration
+	}{
+		{"no interval", time.Hour, 0, nil},
+		{"empty file", 0, 5 * time.Minute, nil},
+		{"shorter than the interval", 3 * time.Minute, 5 * time.Minute, []time.Duration{0}},
+		{"exact multiple", 15 * time.Minute, 5 * time.Minute, []time.Duration{0, 5 * time.Minute, 10 * time.Minute}},
+		{"last chapter shorter", 12 * time.Minute, 5 * time.Minute, []time.Duration{0, 5 * time.Minute, 10 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IntervalChapters(tt.length, tt.interval)
+			if !slices.Equal(starts(got), tt.want) {
+				t.Errorf("IntervalChapters() starts = %v, want %v", starts(got), tt.want)
+			}
+			if len(got) > 0 && got[len(got)-1].End != tt.length {
+				t.Errorf("last chapter ends at %v, want %v", got[len(got)-1].End, tt.length)
+			}
+		})
+	}
+}
+
+func TestSceneChapters(t *testing.T) {
+	tests := []struct {
+		name   string
+		cuts   []time.Duration
+		length time.Duration
+		want   []time.Duration
+	}{
+		{"empty file", []time.Duration{time.Minute}, 0, nil},
+		{"no cuts", nil, 10 * time.Minute, []time.Duration{0}},
+		{"cuts apart", []time.Duration{3 * time.Minute, 6 * time.Minute}, 10 * time.Minute,
+			[]time.Duration{0, 3 * time.Minute, 6 * time.Minute}},
+		{"cut near the start", []time.Duration{time.Minute, 3 * time.Minute}, 10 * time.Minute,
+			[]time.Duration{0, 3 * time.Minute}},
+		{"cuts near each other", []time.Duration{3 * time.Minute, 4 * time.Minute, 5 * time.Minute}, 10 * time.Minute,
+			[]time.Duration{0, 3 * time.Minute, 5 * time.Minute}},
+		{"cut near the end", []time.Duration{3 * time.Minute, 9 * time.Minute}, 10 * time.Minute,
+			[]time.Duration{0, 3 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SceneChapters(tt.cuts, tt.length, 2*time.Minute)
+			if !slices.Equal(starts(got), tt.want) {
+				t.Errorf("SceneChapters() starts = %v, want %v", starts(got), tt.want)
+			}
+			for i, c := range got {
+				if i+1 < len(got) && c.End != got[i+1].Start {
+					t.Errorf("chapter %d ends at %v, next starts at %v", i+1, c.End, got[i+1].Start)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSceneCuts(t *testing.T) {
+	output := `Input #0, matroska,webm, from 'clip.mkv':
+[Parsed_showinfo_1 @ 0x55d0] config in time_base: 1/1000, frame_rate: 25/1
+[Parsed_showinfo_1 @ 0x55d0] n:   0 pts:  41240 pts_time:41.24   duration:     40 fmt:yuv420p
+[Parsed_showinfo_1 @ 0x55d0] n:   1 pts: 183000 pts_time:183     duration:     40 fmt:yuv420p
+[Parsed_showinfo_1 @ 0x55d0] n:   2 pts_time:bad
+[other @ 0x55d0] pts_time:12.5
+frame=    3 fps=0.0 q=-0.0 Lsize=N/A time=00:03:03.04`
+	want := []time.Duration{41240 * time.Millisecond, 183 * time.Second}
+	if got := ParseSceneCuts(output); !slices.Equal(got, want) {
+		t.Errorf("ParseSceneCuts() = %v, want %v", got, want)
+	}
+	if got := ParseSceneCuts(""); got != nil {
+		t.Errorf("ParseSceneCuts(\"\") = %v", got)
+	}
+}
+
+func TestSceneDetectArgs(t *testing.T) {
+	j := &Job{Source: &util.MediaInfo{FullPath: "/video/clip.mkv"}, Chapter: NewChapterSettings()}
+	want := []string{"-hide_banner", "-nostats", "-i", "/video/clip.mkv",
+		"-map", "0:v:0", "-vf", "select='gt(scene,0.400)',showinfo", "-an", "-f", "null", "-"}
+	if got := SceneDetectArgs(j); !slices.Equal(got, want) {
+		t.Errorf("SceneDetectArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestFFMetadata(t *testing.T) {
+	chapters := []util.ChapterMark{
+		{Start: 0, End: 90500 * time.Millisecond, Title: "Intro"},
+		{Start: 90500 * time.Millisecond, End: 5 * time.Minute, Title: "A=B; #1 \\ end"},
+		{Start: 5 * time.Minute, End: 6 * time.Minute},
+	}
+	want := `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=90500
+title=Intro
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=90500
+END=300000
+title=A\=B\; \#1 \\ end
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=300000
+END=360000
+`
+	if got := FFMetadata(chapters); got != want {
+		t.Errorf("FFMetadata() = %q, want %q", got, want)
+	}
+	if got := FFMetadata(nil); got != ";FFMETADATA1\n" {
+		t.Errorf("FFMetadata(nil) = %q", got)
+	}
+}
+
+func TestOGMChapters(t *testing.T) {
+	chapters := []util.ChapterMark{
+		{Start: 0, End: 90500 * time.Millisecond, Title: "Intro"},
+		{Start: 90500 * time.Millisecond, End: 2 * time.Hour},
+	}
+	want := "CHAPTER01=00:00:00.000\nCHAPTER01NAME=Intro\n" +
+		"CHAPTER02=00:01:30.500\nCHAPTER02NAME=Chapter 2\n"
+	if got := OGMChapters(chapters); got != want {
+		t.Errorf("OGMChapters() = %q, want %q", got, want)
+	}
+}
+
+func TestChapterMuxArgs(t *testing.T) {
+	j := &Job{Container: ContainerMKV}
+	inputs, maps := chapterMuxArgs(j, "/work/clip.ffmeta", 3)
+	if want := []string{"-i", "/work/clip.ffmeta"}; !slices.Equal(inputs, want) {
+		t.Errorf("chapterMuxArgs() inputs = %q, want %q", inputs, want)
+	}
+	if want := []string{"-map_chapters", "3"}; !slices.Equal(maps, want) {
+		t.Errorf("chapterMuxArgs() maps = %q, want %q", maps, want)
+	}
+
+	// AVI can't carry chapters, the chapters of the inputs are dropped
+	drop := []string{"-map_chapters", "-1"}
+	j.Container = ContainerAVI
+	if inputs, maps := chapterMuxArgs(j, "/work/clip.ffmeta", 3); inputs != nil || !slices.Equal(maps, drop) {
+		t.Errorf("chapterMuxArgs() for AVI = %q, %q, want %q", inputs, maps, drop)
+	}
+	j.Container = ContainerMP4
+	if inputs, maps := chapterMuxArgs(j, "", 3); inputs != nil || !slices.Equal(maps, drop) {
+		t.Errorf("chapterMuxArgs() without chapter file = %q, %q, want %q", inputs, maps, drop)
+	}
+}
+
+func TestTrimChapters(t *testing.T) {
+	chapters := []util.ChapterMark{
+		{Start: 0, End: 4 * time.Minute, Title: "Opening"},
+		{Start: 4 * time.Minute, End: 10 * time.Minute, Title: "Main"},
+		{Start: 10 * time.Minute, End: 12 * time.Minute, Title: "Credits"},
+	}
+	tests := []struct {
+		name          string
+		start, length time.Duration
+		want          []util.ChapterMark
+	}{
+		{"untrimmed", 0, 12 * time.Minute, chapters},
+		{"start in a chapter", 5 * time.Minute, 7 * time.Minute, []util.ChapterMark{
+			{Start: 0, End: 5 * time.Minute, Title: "Main"},
+			{Start: 5 * time.Minute, End: 7 * time.Minute, Title: "Credits"},
+		}},
+		{"end in a chapter", 0, 6 * time.Minute, []util.ChapterMark{
+			{Start: 0, End: 4 * time.Minute, Title: "Opening"},
+			{Start: 4 * time.Minute, End: 6 * time.Minute, Title: "Main"},
+		}},
+		{"start at a chapter", 4 * time.Minute, 6 * time.Minute, []util.ChapterMark{
+			{Start: 0, End: 6 * time.Minute, Title: "Main"},
+		}},
+		{"nothing left", 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimChapters(chapters, tt.start, tt.length); !slices.Equal(got, tt.want) {
+				t.Errorf("trimChapters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChapters(t *testing.T) {
+	source := []util.ChapterMark{
+		{Start: 0, End: 4 * time.Minute, Title: "Opening"},
+		{Start: 4 * time.Minute, End: 10 * time.Minute, Title: "Main"},
+	}
+	tests := []struct {
+		name    string
+		chapter ChapterSettings
+		cuts    []time.Duration
+		want    []time.Duration
+	}{
+		{"none", ChapterSettings{}, nil, nil},
+		{"source", NewChapterSettings(), nil, []time.Duration{0, 4 * time.Minute}},
+		{"interval", ChapterSettings{Mode: ChaptersInterval, Interval: 3 * time.Minute}, nil,
+			[]time.Duration{0, 3 * time.Minute, 6 * time.Minute, 9 * time.Minute}},
+		{"scenes", ChapterSettings{Mode: ChaptersScenes, Interval: 2 * time.Minute},
+			[]time.Duration{time.Minute, 5 * time.Minute}, []time.Duration{0, 5 * time.Minute}},
+		{"scenes not detected", ChapterSettings{Mode: ChaptersScenes, Interval: 2 * time.Minute}, nil,
+			[]time.Duration{0}},
+	}
+	// the chapters and cuts of the trimmed source, 1 to 9 minutes
+	trimmed := []struct {
+		name    string
+		chapter ChapterSettings
+		cuts    []time.Duration
+		want    []time.Duration
+	}{
+		{"source", NewChapterSettings(), nil, []time.Duration{0, 3 * time.Minute}},
+		{"interval", ChapterSettings{Mode: ChaptersInterval, Interval: 3 * time.Minute}, nil,
+			[]time.Duration{0, 3 * time.Minute, 6 * time.Minute}},
+		{"scenes", ChapterSettings{Mode: ChaptersScenes, Interval: 2 * time.Minute},
+			[]time.Duration{30 * time.Second, 5 * time.Minute, 9*time.Minute + 30*time.Second},
+			[]time.Duration{0, 4 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{
+				Source:    &util.MediaInfo{Duration: 10 * time.Minute, Chapters: source},
+				Chapter:   tt.chapter,
+				SceneCuts: tt.cuts,
+			}
+			if got := starts(j.Chapters()); !slices.Equal(got, tt.want) {
+				t.Errorf("Chapters() starts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, tt := range trimmed {
+		t.Run("trimmed "+tt.name, func(t *testing.T) {
+			j := &Job{
+				Source:    &util.MediaInfo{Duration: 10 * time.Minute, Chapters: source},
+				Chapter:   tt.chapter,
+				SceneCuts: tt.cuts,
+				TrimStart: time.Minute,
+				TrimEnd:   9 * time.Minute,
+			}
+			if got := starts(j.Chapters()); !slices.Equal(got, tt.want) {
+				t.Errorf("Chapters() starts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// FFmpegBackend encodes the source directly with ffmpeg. Scaling, trimming
+// and the audio processing of the AviSynth+ script are done with ffmpeg
+// filters.
+type FFmpegBackend struct {
+	Tools
+}
+
+// Name returns the name of the backend.
+func (b *FFmpegBackend) Name() string {
+	return BackendFFmpeg
+}
+
+// Check returns an error if the job can't be converted.
+func (b *FFmpegBackend) Check(j *Job) error {
+	if b.FFmpeg == "" {
+		return fmt.Errorf("ffmpeg path not configured")
+	}
+	if j.Frameserver == FrameserverVapourSynth && b.VSPipe == "" {
+		return fmt.Errorf("vspipe path not configured")
+	}
+	if err := checkEncoder(j); err != nil {
+		return err
+	}
+	if err := checkSubtitles(j); err != nil {
+		return err
+	}
+	return j.Audio.Validate(j.Container)
+}
+
+// Run encodes the job and extracts the sidecar subtitles. In two-stage
+// mode the filtered video is rendered into the lossless intermediate first,
+// which is reused while the filters don't change. Two-pass presets analyse
+// the video in a first pass. The output is verified with the verification
+// mode of the job and analysed if requested, the intermediate files are
+// deleted afterwards with Cleanup.
+func (b *FFmpegBackend) Run(ctx context.Context, j *Job, report func(Progress)) error {
+	if err := b.Check(j); err != nil {
+		return err
+	}
+	if err := prepareDirs(j); err != nil {
+		return err
+	}
+	chapters, _, err := writeChapters(j)
+	if err != nil {
+		return err
+	}
+
+	var script string
+	var video []string // input of the rendered video, nil reads the source
+	if j.Frameserver == FrameserverVapourSynth {
+		script = j.WorkPath(".vpy")
+		if err := j.writeWorkFile(script, VapourSynthScript(j, b.VapourSynthPlugins)); err != nil {
+			return err
+		}
+		j.Workspace.Track(j.IndexPath())
+		video = []string{"-f", "yuv4mpegpipe", "-i", "-"}
+	}
+	encode := func(args []string, stage string) error {
+		if script != "" {
+			_, err := runVSPipe(ctx, b.Tools, script, args, stage, j.OutputDuration(), report)
+			return err
+		}
+		return runFFmpeg(ctx, b.FFmpeg, args, stage, j.OutputDuration(), report)
+	}
+
+	if j.Intermediate != LosslessNone {
+		var scripts []string
+		if script != "" {
+			scripts = append(scripts, script)
+		}
+		key, err := stageOneKey(j, b.Name(), scripts, LosslessArgs(j, video))
+		if err != nil {
+			return err
+		}
+		if err := renderStageOne(j, key, func() error { return encode(LosslessArgs(j, video), "stage 1") }, report); err != nil {
+			return err
+		}
+		// stage two encodes the intermediate
+		script, video = "", []string{"-i", j.LosslessPath()}
+	}
+
+	if j.Preset.TwoPass() {
+		trackPassLogs(j)
+		if err := encode(FirstPassArgs(j, video), "pass 1"); err != nil {
+			return err
+		}
+		if err := encode(FFmpegArgs(j, chapters, video), "pass 2"); err != nil {
+			return err
+		}
+	} else if err := encode(FFmpegArgs(j, chapters, video), "encode"); err != nil {
+		return err
+	}
+	if err := extractSidecars(ctx, b.FFmpeg, j, report); err != nil {
+		return err
+	}
+	if err := verify(ctx, b.FFmpeg, j, report); err != nil {
+		return err
+	}
+	if err := analyze(ctx, b.Tools, b.Name(), j, report); err != nil {
+		return err
+	}
+	cleanup(j)
+	return nil
+}
+
+// FFmpegArgs returns the ffmpeg arguments converting the source of the job
+// into the output with the chapters of the chapter file. video are the
+// input arguments of the already filtered video, rendered by vspipe into
+// stdin or by stage one into the lossless intermediate. With nil the video
+// is read from the source.
+func FFmpegArgs(j *Job, chapterFile string, video []string) []string {
+	args := append([]string{"-y"}, video...)
+	source := countInputs(video)
+	args = append(args, j.seekArgs()...)
+	args = append(args, "-i", j.Source.FullPath)
+
+	subInputs, subMaps := subtitleMuxArgs(j, source+1)
+	chapterInputs, chapterMaps := chapterMuxArgs(j, chapterFile, source+1+countInputs(subInputs))
+	args = append(args, subInputs...)
+	args = append(args, chapterInputs...)
+
+	args = append(args, videoMapArgs(j, video)...)
+	args = append(args, encodeArgs(j)...)
+	args = append(args, sourceAudioArgs(j, source)...)
+	args = append(args, audioArgs(j)...)
+	args = append(args, subMaps...)
+	args = append(args, chapterMaps...)
+	return append(args, j.OutputPath())
+}
+
+// FirstPassArgs returns the ffmpeg arguments of the first pass of a
+// two-pass encode, which only writes the pass log. video are the input
+// arguments like in FFmpegArgs.
+func FirstPassArgs(j *Job, video []string) []string {
+	args := append([]string{"-y"}, videoInputArgs(j, video)...)
+	args = append(args, VideoArgs(j.Preset)...)
+	args = append(args, passArgs(j, 1)...)
+	return append(args, "-an", "-sn", "-f", "null", "-")
+}
+
+// videoInputArgs returns the input of the video and its map and filters.
+func videoInputArgs(j *Job, video []string) []string {
+	if video != nil {
+		return append(append([]string{}, video...), videoMapArgs(j, video)...)
+	}
+	args := append(j.seekArgs(), "-i", j.Source.FullPath)
+	return append(args, videoMapArgs(j, nil)...)
+}
+
+// videoMapArgs returns the map of the video and the filters scaling the
+// source. Rendered video is already scaled and trimmed.
+func videoMapArgs(j *Job, video []string) []string {
+	if video != nil {
+		return []string{"-map", "0:v"}
+	}
+	var args []string
+	if v := j.Source.FirstVideo(); v != nil {
+		args = append(args, "-map", fmt.Sprintf("0:%d", v.Index))
+	}
+	if filters := videoFilters(j); len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+	return args
+}
+
+// videoFilters returns the ffmpeg filters of the filter chain and the
+// scaling of the job.
+func videoFilters(j *Job) []string {
+	filters := j.Filters.FFmpeg()
+	if w, h := j.OutputSize(); w > 0 {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d:flags=spline", w, h))
+	}
+	return filters
+}
+
+// encodeArgs returns the encoder options of the preset, with the options
+// of the second pass for two-pass presets.
+func encodeArgs(j *Job) []string {
+	args := VideoArgs(j.Preset)
+	if j.Preset.TwoPass() {
+		args = append(args, passArgs(j, 2)...)
+	}
+	return args
+}
+
+// VideoArgs returns the ffmpeg encoder options of the preset.
+func VideoArgs(p Preset) []string {
+	args := []string{"-c:v", p.Codec}
+	if p.PixelFormat != "" {
+		args = append(args, "-pix_fmt", p.PixelFormat)
+	}
+	if p.Profile != "" {
+		args = append(args, "-profile:v", p.Profile)
+	}
+	if p.Level != "" {
+		args = append(args, "-level:v", p.Level)
+	}
+	if p.Tune != "" {
+		args = append(args, "-tune", p.Tune)
+	}
+	switch p.RateControl {
+	case RateCRF:
+		args = append(args, "-crf", fmt.Sprint(p.CRF))
+	case RateBitrate, RateTwoPass:
+		args = append(args, "-b:v", fmt.Sprintf("%dk", p.Bitrate))
+	}
+	return args
+}
+
+// sourceAudioArgs returns the maps and filters of the selected audio tracks
+// read from the source, which is the input with the number.
+func sourceAudioArgs(j *Job, input int) []string {
+	var args []string
+	for i, a := range j.SelectedAudio() {
+		args = append(args, "-map", fmt.Sprintf("%d:%d", input, a.Index))
+		if filter := audioFilter(j, a); filter != "" {
+			args = append(args, fmt.Sprintf("-filter:a:%d", i), filter)
+		}
+	}
+	return args
+}
+
+// audioFilter returns the ffmpeg filter chain doing the same as the audio
+// lines of the AviSynth+ script. ffmpeg keeps the start times of the
+// streams, only the manual delay is applied.
+func audioFilter(j *Job, a util.AudioStream) string {
+	var filters []string
+	switch delay := j.manualDelay(); {
+	case delay > 0:
+		filters = append(filters, fmt.Sprintf("adelay=%d:all=1", delay.Milliseconds()))
+	case delay < 0:
+		filters = append(filters, fmt.Sprintf("atrim=start=%s", seconds(-delay)), "asetpts=PTS-STARTPTS")
+	}
+
+	switch j.Audio.Downmix {
+	case DownmixStereo:
+		switch {
+		case a.Channels >= 6:
+			// ITU coefficients of the DownmixStereo AviSynth function
+			filters = append(filters, "pan=stereo|c0=0.4142*c0+0.2929*c2+0.2929*c4|c1=0.4142*c1+0.2929*c2+0.2929*c5")
+		case a.Channels > 2:
+			filters = append(filters, "pan=stereo|c0=c0|c1=c1")
+		}
+	case DownmixMono:
+		if a.Channels != 1 {
+			filters = append(filters, "aformat=channel_layouts=mono")
+		}
+	}
+
+	if rate := j.Audio.SampleRate; rate > 0 && rate != a.SampleRate {
+		filters = append(filters, fmt.Sprintf("aresample=%d", rate))
+	}
+	return strings.Join(filters, ",")
+}
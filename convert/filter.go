@@ -0,0 +1,519 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ParamKind defines the type of a filter parameter and the widget editing it.
+type ParamKind int
+
+const (
+	ParamRange ParamKind = iota // number between Min and Max, edited with a slider
+	ParamEnum                   // one of the Options, edited with a choice
+	ParamBool                   // "true" or "false", edited with a check button
+)
+
+// ParamDef describes a parameter of a filter.
+type ParamDef struct {
+	Name    string // key in the parameters of the filter
+	Label   string // label shown in the editor
+	Kind    ParamKind
+	Min     float64  // smallest value of a range
+	Max     float64  // largest value of a range
+	Step    float64  // step of a range, 1 for integers
+	Options []string // values of an enum
+	Default string   // value of a new filter
+}
+
+// Validate returns an error if the value is not valid for the parameter.
+func (d ParamDef) Validate(value string) error {
+	switch d.Kind {
+	case ParamRange:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", d.Label, value)
+		}
+		if f < d.Min || f > d.Max {
+			return fmt.Errorf("%s: %s is not between %g and %g", d.Label, value, d.Min, d.Max)
+		}
+		if d.Step == 1 && f != float64(int64(f)) {
+			return fmt.Errorf("%s: %s is not an integer", d.Label, value)
+		}
+	case ParamEnum:
+		if !slices.Contains(d.Options, value) {
+			return fmt.Errorf("%s: %q is not one of %s", d.Label, value, strings.Join(d.Options, ", "))
+		}
+	case ParamBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s: %q is not true or false", d.Label, value)
+		}
+	}
+	return nil
+}
+
+// FilterValues are the validated parameters of a filter with the defaults
+// filled in.
+type FilterValues map[string]string
+
+// Float returns the value of a range parameter.
+func (v FilterValues) Float(name string) float64 {
+	f, _ := strconv.ParseFloat(v[name], 64)
+	return f
+}
+
+// Int returns the value of an integer range parameter.
+func (v FilterValues) Int(name string) int {
+	return int(v.Float(name))
+}
+
+// Bool returns the value of a bool parameter.
+func (v FilterValues) Bool(name string) bool {
+	b, _ := strconv.ParseBool(v[name])
+	return b
+}
+
+// FilterDef describes a video filter and its call in the AviSynth+ and
+// VapourSynth scripts and as ffmpeg filter. The script functions return an
+// expression filtering the clip "video" in AviSynth+ and "clip" in
+// VapourSynth.
+type FilterDef struct {
+	Name        string
+	Description string
+	Params      []ParamDef
+	Imports     []string // Python imports of the VapourSynth expression
+	Plugins     []string // AviSynth+ plugins of the AviSynth+ expression, see knownPlugins
+	AviSynth    func(v FilterValues) string
+	VapourSynth func(v FilterValues) string
+	FFmpeg      func(v FilterValues) string
+}
+
+// Param returns the definition of the parameter with the name.
+func (d *FilterDef) Param(name string) (ParamDef, bool) {
+	for _, p := range d.Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return ParamDef{}, false
+}
+
+// qtgmcPresets are the speed presets of QTGMC, slowest first.
+var qtgmcPresets = []string{"Placebo", "Very Slow", "Slower", "Slow", "Medium", "Fast", "Faster", "Very Fast", "Super Fast", "Ultra Fast", "Draft"}
+
+// filterDefs are the known filters in the order shown in the editor.
+var filterDefs = []FilterDef{
+	{
+		Name:        "Deinterlace",
+		Description: "QTGMC deinterlacer, bwdif with ffmpeg",
+		Plugins:     []string{"QTGMC"},
+		Params: []ParamDef{
+			{Name: "preset", Label: "Preset", Kind: ParamEnum, Options: qtgmcPresets, Default: "Slower"},
+			{Name: "double", Label: "Double rate", Kind: ParamBool, Default: "true"},
+			{Name: "tff", Label: "Top field first", Kind: ParamBool, Default: "true"},
+		},
+		Imports: []string{"import havsfunc as haf"},
+		AviSynth: func(v FilterValues) string {
+			order := "AssumeBFF(video)"
+			if v.Bool("tff") {
+				order = "AssumeTFF(video)"
+			}
+			return fmt.Sprintf("QTGMC(%s, Preset=%q, FPSDivisor=%d)", order, v["preset"], fpsDivisor(v))
+		},
+		VapourSynth: func(v FilterValues) string {
+			return fmt.Sprintf("haf.QTGMC(clip, Preset=%q, FPSDivisor=%d, TFF=%s)", v["preset"], fpsDivisor(v), pyBool(v.Bool("tff")))
+		},
+		FFmpeg: func(v FilterValues) string {
+			mode, parity := "send_frame", "bff"
+			if v.Bool("double") {
+				mode = "send_field"
+			}
+			if v.Bool("tff") {
+				parity = "tff"
+			}
+			return fmt.Sprintf("bwdif=mode=%s:parity=%s", mode, parity)
+		},
+	},
+	{
+		Name:        "Crop",
+		Description: "Remove borders, the sizes are rounded to even numbers",
+		Params: []ParamDef{
+			{Name: "left", Label: "Left", Kind: ParamRange, Max: 400, Step: 2, Default: "0"},
+			{Name: "top", Label: "Top", Kind: ParamRange, Max: 400, Step: 2, Default: "0"},
+			{Name: "right", Label: "Right", Kind: ParamRange, Max: 400, Step: 2, Default: "0"},
+			{Name: "bottom", Label: "Bottom", Kind: ParamRange, Max: 400, Step: 2, Default: "0"},
+		},
+		AviSynth: func(v FilterValues) string {
+			l, t, r, b := cropSizes(v)
+			return fmt.Sprintf("Crop(video, %d, %d, %d, %d)", l, t, -r, -b)
+		},
+		VapourSynth: func(v FilterValues) string {
+			l, t, r, b := cropSizes(v)
+			return fmt.Sprintf("core.std.Crop(clip, left=%d, right=%d, top=%d, bottom=%d)", l, r, t, b)
+		},
+		FFmpeg: func(v FilterValues) string {
+			l, t, r, b := cropSizes(v)
+			return fmt.Sprintf("crop=iw-%d:ih-%d:%d:%d", l+r, t+b, l, t)
+		},
+	},
+	{
+		Name:        "Denoise",
+		Description: "FFT3DFilter, hqdn3d with ffmpeg",
+		Plugins:     []string{"FFT3DFilter"},
+		Params: []ParamDef{
+			{Name: "sigma", Label: "Strength", Kind: ParamRange, Min: 0.5, Max: 10, Step: 0.5, Default: "2"},
+		},
+		AviSynth: func(v FilterValues) string {
+			return fmt.Sprintf("FFT3DFilter(video, sigma=%g)", v.Float("sigma"))
+		},
+		VapourSynth: func(v FilterValues) string {
+			return fmt.Sprintf("core.fft3dfilter.FFT3DFilter(clip, sigma=%g)", v.Float("sigma"))
+		},
+		FFmpeg: func(v FilterValues) string {
+			return fmt.Sprintf("hqdn3d=luma_spatial=%g", v.Float("sigma"))
+		},
+	},
+	{
+		Name:        "Deblock",
+		Description: "Deblock for blocky MPEG sources",
+		Plugins:     []string{"Deblock"},
+		Params: []ParamDef{
+			{Name: "quant", Label: "Quantizer", Kind: ParamRange, Min: 0, Max: 60, Step: 1, Default: "25"},
+		},
+		AviSynth: func(v FilterValues) string {
+			return fmt.Sprintf("Deblock(video, quant=%d)", v.Int("quant"))
+		},
+		VapourSynth: func(v FilterValues) string {
+			return fmt.Sprintf("core.deblock.Deblock(clip, quant=%d)", v.Int("quant"))
+		},
+		FFmpeg: func(v FilterValues) string {
+			// deblock of ffmpeg has no quantizer, map it to the strength
+			filter := "weak"
+			if v.Int("quant") > 30 {
+				filter = "strong"
+			}
+			return "deblock=filter=" + filter
+		},
+	},
+	{
+		Name:        "Sharpen",
+		Description: "Contrast adaptive sharpening",
+		Plugins:     []string{"CAS"},
+		Params: []ParamDef{
+			{Name: "sharpness", Label: "Sharpness", Kind: ParamRange, Min: 0, Max: 1, Step: 0.05, Default: "0.5"},
+		},
+		AviSynth: func(v FilterValues) string {
+			return fmt.Sprintf("CAS(video, sharpness=%g)", v.Float("sharpness"))
+		},
+		VapourSynth: func(v FilterValues) string {
+			return fmt.Sprintf("core.cas.CAS(clip, sharpness=%g)", v.Float("sharpness"))
+		},
+		FFmpeg: func(v FilterValues) string {
+			return fmt.Sprintf("cas=strength=%g", v.Float("sharpness"))
+		},
+	},
+	{
+		Name:        "Colormatrix",
+		Description: "Convert SD colors to HD colors and back",
+		Plugins:     []string{"ColorMatrix"},
+		Params: []ParamDef{
+			{Name: "mode", Label: "Conversion", Kind: ParamEnum, Options: []string{"Rec.601->Rec.709", "Rec.709->Rec.601"}, Default: "Rec.601->Rec.709"},
+		},
+		AviSynth: func(v FilterValues) string {
+			return fmt.Sprintf("ColorMatrix(video, mode=%q)", v["mode"])
+		},
+		VapourSynth: func(v FilterValues) string {
+			in, out := "170m", "709"
+			if v["mode"] == "Rec.709->Rec.601" {
+				in, out = out, in
+			}
+			return fmt.Sprintf("core.resize.Bicubic(clip, matrix_in_s=%q, matrix_s=%q)", in, out)
+		},
+		FFmpeg: func(v FilterValues) string {
+			if v["mode"] == "Rec.709->Rec.601" {
+				return "colormatrix=bt709:bt601"
+			}
+			return "colormatrix=bt601:bt709"
+		},
+	},
+}
+
+// FilterNames returns the names of the known filters.
+func FilterNames() []string {
+	names := make([]string, len(filterDefs))
+	for i, d := range filterDefs {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// LookupFilter returns the definition of the filter with the name.
+func LookupFilter(name string) (*FilterDef, bool) {
+	for i := range filterDefs {
+		if filterDefs[i].Name == name {
+			return &filterDefs[i], true
+		}
+	}
+	return nil, false
+}
+
+// fpsDivisor returns the FPSDivisor of QTGMC, 1 keeps the doubled rate.
+func fpsDivisor(v FilterValues) int {
+	if v.Bool("double") {
+		return 1
+	}
+	return 2
+}
+
+// cropSizes returns the crop sizes rounded down to even numbers, odd
+// sizes fail with subsampled chroma.
+func cropSizes(v FilterValues) (left, top, right, bottom int) {
+	return v.Int("left") &^ 1, v.Int("top") &^ 1, v.Int("right") &^ 1, v.Int("bottom") &^ 1
+}
+
+// pyBool returns the value as Python bool literal.
+func pyBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
+
+// Filter is a filter of the chain with its parameters.
+type Filter struct {
+	Name    string            `yaml:"name"`
+	Enabled bool              `yaml:"enabled"`
+	Params  map[string]string `yaml:"params,omitempty"`
+}
+
+// NewFilter returns the enabled filter with the default parameters.
+func NewFilter(name string) Filter {
+	f := Filter{Name: name, Enabled: true, Params: map[string]string{}}
+	if d, ok := LookupFilter(name); ok {
+		for _, p := range d.Params {
+			f.Params[p.Name] = p.Default
+		}
+	}
+	return f
+}
+
+// Values returns the parameters of the filter with the defaults of
+// missing parameters.
+func (f Filter) Values() FilterValues {
+	v := FilterValues{}
+	if d, ok := LookupFilter(f.Name); ok {
+		for _, p := range d.Params {
+			v[p.Name] = p.Default
+		}
+	}
+	for name, value := range f.Params {
+		v[name] = value
+	}
+	return v
+}
+
+// Validate returns an error if the filter or one of its parameters is unknown
+// or a value is invalid.
+func (f Filter) Validate() error {
+	d, ok := LookupFilter(f.Name)
+	if !ok {
+		return fmt.Errorf("unknown filter %q", f.Name)
+	}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(f.Params)) {
+		value := f.Params[name]
+		p, ok := d.Param(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown parameter %q", f.Name, name))
+			continue
+		}
+		if err := p.Validate(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FilterChain is the list of filters applied to the video in this order.
+type FilterChain []Filter
+
+// Clone returns a copy of the chain with copies of the parameters.
+func (c FilterChain) Clone() FilterChain {
+	if c == nil {
+		return nil
+	}
+	clone := make(FilterChain, len(c))
+	for i, f := range c {
+		clone[i] = f
+		clone[i].Params = maps.Clone(f.Params)
+	}
+	return clone
+}
+
+// Validate returns the errors of all filters.
+func (c FilterChain) Validate() error {
+	var errs []error
+	for _, f := range c {
+		errs = append(errs, f.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// active calls fn for the enabled, known filters.
+func (c FilterChain) active(fn func(d *FilterDef, v FilterValues)) {
+	for _, f := range c {
+		if !f.Enabled {
+			continue
+		}
+		if d, ok := LookupFilter(f.Name); ok {
+			fn(d, f.Values())
+		}
+	}
+}
+
+// AviSynth returns the AviSynth+ lines filtering the clip "video".
+func (c FilterChain) AviSynth() []string {
+	var lines []string
+	c.active(func(d *FilterDef, v FilterValues) {
+		lines = append(lines, "video = "+d.AviSynth(v))
+	})
+	return lines
+}
+
+// VapourSynth returns the Python imports of the filters and the lines
+// filtering the clip "clip".
+func (c FilterChain) VapourSynth() (imports, lines []string) {
+	c.active(func(d *FilterDef, v FilterValues) {
+		for _, imp := range d.Imports {
+			if !slices.Contains(imports, imp) {
+				imports = append(imports, imp)
+			}
+		}
+		lines = append(lines, "clip = "+d.VapourSynth(v))
+	})
+	return imports, lines
+}
+
+// FFmpeg returns the ffmpeg filters of the chain, comma separated.
+func (c FilterChain) FFmpeg() []string {
+	var filters []string
+	c.active(func(d *FilterDef, v FilterValues) {
+		filters = append(filters, d.FFmpeg(v))
+	})
+	return filters
+}
+
+// DoublesRate returns true if the chain doubles the frame rate, like bob
+// deinterlacing.
+func (c FilterChain) DoublesRate() bool {
+	double := false
+	c.active(func(d *FilterDef, v FilterValues) {
+		if d.Name == "Deinterlace" && v.Bool("double") {
+			double = true
+		}
+	})
+	return double
+}
+
+// FrameSize returns the frame size after cropping.
+func (c FilterChain) FrameSize(width, height int) (int, int) {
+	c.active(func(d *FilterDef, v FilterValues) {
+		if d.Name == "Crop" {
+			l, t, r, b := cropSizes(v)
+			width, height = width-l-r, height-t-b
+		}
+	})
+	return width, height
+}
+
+// FilterPreset is a named filter chain saved by the user.
+type FilterPreset struct {
+	Name    string      `yaml:"name"`
+	Filters FilterChain `yaml:"filters"`
+}
+
+// filterPresetPath returns the file of the filter preset in the directory.
+func filterPresetPath(dir, name string) string {
+	return filepath.Join(dir, sanitizeFileName(name)+".yaml")
+}
+
+// sanitizeFileName replaces the characters not allowed in file names.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// LoadFilterPresets returns the filter presets of all YAML files in the
+// directory sorted by name. Invalid files are skipped and reported in the
+// error, a missing directory is no error.
+func LoadFilterPresets(dir string) ([]FilterPreset, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var presets []FilterPreset
+	var errs []error
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var p FilterPreset
+		if err := yaml.UnmarshalStrict(data, &p); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		if err := p.Filters.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		presets = append(presets, p)
+	}
+	slices.SortFunc(presets, func(a, b FilterPreset) int { return strings.Compare(a.Name, b.Name) })
+	return presets, errors.Join(errs...)
+}
+
+// SaveFilterPreset writes the filter preset into the directory, replacing
+// the preset with the same name.
+func SaveFilterPreset(dir string, p FilterPreset) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("filter preset has no name")
+	}
+	if err := p.Filters.Validate(); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filterPresetPath(dir, p.Name), data, 0o644)
+}
+
+// DeleteFilterPreset removes the filter preset from the directory.
+func DeleteFilterPreset(dir, name string) error {
+	return os.Remove(filterPresetPath(dir, name))
+}
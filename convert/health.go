@@ -0,0 +1,190 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// versionTimeout limits the time a tool may take to print its version.
+const versionTimeout = 10 * time.Second
+
+// ToolCheck is the result of checking an external program or plugin.
+type ToolCheck struct {
+	Name     string // program or plugin like "ffmpeg" or "QTGMC"
+	Path     string // configured path, resolved with the search path
+	Version  string // reported version, empty if the tool doesn't report one
+	Required bool   // needed by the configured backend
+	Err      error  // nil if the tool can be used
+}
+
+// OK returns true if the check passed.
+func (c ToolCheck) OK() bool {
+	return c.Err == nil
+}
+
+// CheckInstallation checks ffprobe, ffmpeg, VirtualDub2, AviSynth+,
+// vspipe, Wine if it is enabled and the AviSynth+ plugins. Each program
+// has to exist, be executable and report a version. The tools needed by
+// the backend and the frameserver are marked as required.
+func CheckInstallation(ctx context.Context, t Tools, ffprobe, backend string, fs Frameserver) []ToolCheck {
+	avisynth := backend == BackendVirtualDub && fs == FrameserverAviSynth
+	checks := []ToolCheck{
+		checkVersion(ctx, "ffprobe", ffprobe, true, "-version"),
+		checkVersion(ctx, "ffmpeg", t.FFmpeg, true, "-version"),
+		checkVirtualDub(t.VirtualDub, backend == BackendVirtualDub, t.Wine),
+		checkAviSynth(ctx, t.FFmpeg),
+		checkVersion(ctx, "vspipe", t.VSPipe, fs == FrameserverVapourSynth, "--version"),
+	}
+	if t.Wine.Enabled() {
+		checks = append(checks, checkVersion(ctx, "Wine", t.Wine.Launcher, backend == BackendVirtualDub, "--version"))
+	}
+	return append(checks, checkPlugins(t.AviSynthPlugins, avisynth)...)
+}
+
+// MissingRequired returns the failed checks of required tools.
+func MissingRequired(checks []ToolCheck) []ToolCheck {
+	var missing []ToolCheck
+	for _, c := range checks {
+		if c.Required && !c.OK() {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// lookTool returns the executable of the path, searched in PATH if it has
+// no directory.
+func lookTool(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path not configured")
+	}
+	return exec.LookPath(path)
+}
+
+// checkVersion runs the program with the arguments printing its version
+// and takes the first line of the output as version.
+func checkVersion(ctx context.Context, name, path string, required bool, args ...string) ToolCheck {
+	c := ToolCheck{Name: name, Path: path, Required: required}
+	if c.Path, c.Err = lookTool(path); c.Err != nil {
+		c.Path = path
+		return c
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, c.Path, args...).CombinedOutput()
+	if err != nil {
+		c.Err = fmt.Errorf("%w: %s", err, lastLines(string(out), 1))
+		return c
+	}
+	c.Version = firstLine(string(out))
+	if name == "vspipe" {
+		// vspipe prints the copyright first, the version of the core later
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.HasPrefix(line, "Core") {
+				c.Version = strings.TrimSpace(line)
+			}
+		}
+	}
+	if c.Version == "" {
+		c.Err = errors.New("no version reported")
+	}
+	return c
+}
+
+// checkVirtualDub checks the VirtualDub2 executable. VirtualDub2 opens its
+// window when started, the version is not queried. Under Wine the .exe
+// only has to exist.
+func checkVirtualDub(path string, required bool, wine Wine) ToolCheck {
+	c := ToolCheck{Name: "VirtualDub2", Path: path, Required: required}
+	if wine.Enabled() {
+		if path == "" {
+			c.Err = errors.New("path not configured")
+		} else if _, err := os.Stat(path); err != nil {
+			c.Err = err
+		}
+		return c
+	}
+	if c.Path, c.Err = lookTool(path); c.Err != nil {
+		c.Path = path
+	}
+	return c
+}
+
+// avisynthVersion matches the version in the ffmpeg error of the version
+// script, like "AviSynth+ 3.7.3 (r4003, 3.7, x86_64)".
+var avisynthVersion = regexp.MustCompile(`AviSynth\+? [0-9][^\r\n]*`)
+
+// checkAviSynth loads a script failing with the version of AviSynth+ into
+// ffmpeg, which has to be built with AviSynth+ support. VirtualDub2 loads
+// AviSynth+ itself, so the check is not required.
+func checkAviSynth(ctx context.Context, ffmpeg string) ToolCheck {
+	c := ToolCheck{Name: "AviSynth+"}
+	path, err := lookTool(ffmpeg)
+	if err != nil {
+		c.Err = fmt.Errorf("ffmpeg: %w", err)
+		return c
+	}
+	dir, err := os.MkdirTemp("", "avscheck")
+	if err != nil {
+		c.Err = err
+		return c
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "version.avs")
+	if err := os.WriteFile(script, []byte("Assert(false, VersionString())\n"), 0o644); err != nil {
+		c.Err = err
+		return c
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	// the script always fails, the version is in the error message
+	out, _ := exec.CommandContext(ctx, path, "-hide_banner", "-f", "avisynth", "-i", script).CombinedOutput()
+	if v := avisynthVersion.FindString(string(out)); v != "" {
+		c.Version = strings.TrimSpace(v)
+		return c
+	}
+	c.Err = fmt.Errorf("not found or ffmpeg without AviSynth+ support: %s", lastLines(string(out), 1))
+	return c
+}
+
+// checkPlugins checks the known AviSynth+ plugins in the plugin directory,
+// the source filter is required for AviSynth+ scripts.
+func checkPlugins(dir string, avisynth bool) []ToolCheck {
+	if dir == "" {
+		return nil
+	}
+	inv, err := ScanPlugins(dir)
+	if err != nil {
+		return []ToolCheck{{Name: "AviSynth+ plugins", Path: dir, Required: avisynth, Err: err}}
+	}
+	var checks []ToolCheck
+	for _, p := range knownPlugins {
+		c := ToolCheck{Name: p.Name, Path: inv.Path(p.Name), Required: avisynth && p.Name == "LSMASHSource"}
+		if c.Path == "" {
+			c.Path = dir
+			c.Err = errors.New("plugin not found")
+		} else {
+			c.Version = filepath.Base(c.Path)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
+
+// firstLine returns the first non empty line of the text.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+// Package convert generates the scripts to convert a video file: an
+// AviSynth+ script loading and processing the source, a VirtualDub2 script
+// encoding the video and the ffmpeg arguments muxing audio and video into
+// the output container.
+package convert
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// Job holds everything needed to convert a single source file.
+type Job struct {
+	Source       *util.MediaInfo  // probed source file
+	Preset       Preset           // video encoder preset
+	Frameserver  Frameserver      // script language loading and filtering the source
+	Container    Container        // file format of the output, the container of the preset
+	Audio        AudioSettings    // audio conversion of the project
+	AudioTracks  []int            // stream indices of the selected audio tracks, empty for the default track
+	AudioDelay   time.Duration    // delay correction of this file, added to the project delay
+	Subtitles    SubtitleSettings // subtitle handling of the project
+	Chapter      ChapterSettings  // chapters of the output
+	SceneCuts    []time.Duration  // detected scene cuts for scene chapters
+	TrimStart    time.Duration    // start of the converted part of the source
+	TrimEnd      time.Duration    // end of the converted part, 0 for the end of the source
+	Width        int              // output width, 0 keeps the source width or the aspect ratio
+	Height       int              // output height, 0 keeps the source height or the aspect ratio
+	Filters      FilterChain      // video filters applied before scaling
+	TargetSize   int64            // size of the output in bytes, 0 for the rate control of the preset
+	Intermediate LosslessCodec    // codec of the lossless intermediate in two-stage mode
+	Verify       VerifyMode       // check of the output after the conversion
+	Analyze      bool             // compute the quality metrics of the output
+	Scores       *Metrics         // quality metrics, set by the analysis
+	Cleanup      bool             // delete the intermediate files after a successful conversion
+	Workspace    Workspace        // intermediate files created in the work directory
+	Paths        PathMap          // translation of the paths in the scripts read by VirtualDub2
+	WorkDir      string           // directory for scripts and intermediate files
+	OutputDir    string           // directory for the converted file
+}
+
+// BaseName returns the file name of the source without extension.
+func (j *Job) BaseName() string {
+	return strings.TrimSuffix(j.Source.Name, filepath.Ext(j.Source.Name))
+}
+
+// WorkPath returns the path of an intermediate file in the work directory,
+// named after the source with the given extension like ".avs".
+func (j *Job) WorkPath(ext string) string {
+	return filepath.Join(j.WorkDir, j.BaseName()+ext)
+}
+
+// OutputPath returns the path of the converted file.
+func (j *Job) OutputPath() string {
+	return filepath.Join(j.OutputDir, j.BaseName()+"."+string(j.Container))
+}
+
+// SelectedAudio returns the selected audio tracks of the source. Without an
+// explicit selection the default track, or else the first track, is used.
+func (j *Job) SelectedAudio() []util.AudioStream {
+	var tracks []util.AudioStream
+	for _, index := range j.AudioTracks {
+		for _, a := range j.Source.Audio {
+			if a.Index == index {
+				tracks = append(tracks, a)
+			}
+		}
+	}
+	if len(tracks) > 0 || len(j.Source.Audio) == 0 {
+		return tracks
+	}
+
+	for _, a := range j.Source.Audio {
+		if a.Default {
+			return []util.AudioStream{a}
+		}
+	}
+	return j.Source.Audio[:1]
+}
+
+// AudioOffset returns the delay to apply to the audio track. A track
+// starting later than the video is delayed by the difference of the start
+// times, plus the delay of the project and the correction of this file.
+func (j *Job) AudioOffset(a util.AudioStream) time.Duration {
+	offset := j.manualDelay()
+	if v := j.Source.FirstVideo(); v != nil {
+		offset += a.StartTime - v.StartTime
+	}
+	return offset
+}
+
+// manualDelay returns the delay of the project plus the correction of this file.
+func (j *Job) manualDelay() time.Duration {
+	return j.AudioDelay + time.Duration(j.Audio.DelayMs)*time.Millisecond
+}
+
+// OutputDuration returns the playing time of the output after trimming.
+func (j *Job) OutputDuration() time.Duration {
+	end := j.Source.Length()
+	if j.TrimEnd > 0 && j.TrimEnd < end {
+		end = j.TrimEnd
+	}
+	if end < j.TrimStart {
+		return 0
+	}
+	return end - j.TrimStart
+}
+
+// Trimmed returns true if only a part of the source is converted.
+func (j *Job) Trimmed() bool {
+	return j.TrimStart > 0 || j.TrimEnd > 0
+}
+
+// OutputSize returns the frame size of the output, 0, 0 if the source is
+// not scaled. A missing width or height is calculated from the aspect ratio
+// of the cropped source and rounded to an even number.
+func (j *Job) OutputSize() (width, height int) {
+	v := j.Source.FirstVideo()
+	if (j.Width == 0 && j.Height == 0) || v == nil || v.Width == 0 || v.Height == 0 {
+		return 0, 0
+	}
+	srcWidth, srcHeight := j.Filters.FrameSize(v.Width, v.Height)
+	if srcWidth <= 0 || srcHeight <= 0 {
+		return 0, 0
+	}
+	width, height = j.Width, j.Height
+	switch {
+	case width == 0:
+		width = (height*srcWidth/srcHeight + 1) &^ 1
+	case height == 0:
+		height = (width*srcHeight/srcWidth + 1) &^ 1
+	}
+	if width == srcWidth && height == srcHeight {
+		return 0, 0
+	}
+	return width, height
+}
+
+// ScriptRate returns the frame rate of the filtered video, doubled by bob
+// deinterlacing. Trims of the scripts count the frames of this rate.
+func (j *Job) ScriptRate() (mediatime.FrameRate, bool) {
+	v := j.Source.FirstVideo()
+	if v == nil {
+		return mediatime.FrameRate{}, false
+	}
+	if j.Filters.DoublesRate() {
+		return v.FrameRate.Double(), true
+	}
+	return v.FrameRate, true
+}
+
+// seekArgs returns the ffmpeg input options reading only the trimmed part
+// of the source.
+func (j *Job) seekArgs() []string {
+	var args []string
+	if j.TrimStart > 0 {
+		args = append(args, "-ss", seconds(j.TrimStart))
+	}
+	if j.TrimEnd > 0 {
+		args = append(args, "-to", seconds(j.TrimEnd))
+	}
+	return args
+}
+
+// seconds returns the duration as seconds with milliseconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,119 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// LosslessCodec is the codec of the intermediate of a two-stage render.
+type LosslessCodec string
+
+const (
+	LosslessNone    LosslessCodec = ""        // single stage, the video is encoded directly
+	LosslessHuffyuv LosslessCodec = "huffyuv" // Huffyuv, fast with large files
+	LosslessFFV1    LosslessCodec = "ffv1"    // FFV1, smaller and slower
+	LosslessUTVideo LosslessCodec = "utvideo" // UT Video, fast with good compression
+)
+
+// LosslessCodecs lists the codecs of the intermediate.
+var LosslessCodecs = []LosslessCodec{LosslessHuffyuv, LosslessFFV1, LosslessUTVideo}
+
+// FFmpegCodec returns the ffmpeg encoder of the codec.
+func (c LosslessCodec) FFmpegCodec() string {
+	return string(c)
+}
+
+// FourCC returns the VfW codec used by VirtualDub2 for the codec.
+func (c LosslessCodec) FourCC() string {
+	switch c {
+	case LosslessHuffyuv:
+		return "HFYU"
+	case LosslessFFV1:
+		return "FFV1"
+	case LosslessUTVideo:
+		return "ULY0"
+	}
+	return ""
+}
+
+// checkEncoder returns an error if ffmpeg can't encode the job with the
+// preset and the lossless codec.
+func checkEncoder(j *Job) error {
+	if j.Intermediate != LosslessNone && !slices.Contains(LosslessCodecs, j.Intermediate) {
+		return fmt.Errorf("unknown lossless codec %q", j.Intermediate)
+	}
+	if err := j.Preset.Validate(); err != nil {
+		return err
+	}
+	if j.Preset.TwoPass() && !slices.Contains(twoPassCodecs, j.Preset.Codec) {
+		return fmt.Errorf("%s can't encode in two passes", j.Preset.Codec)
+	}
+	return nil
+}
+
+// LosslessPath returns the intermediate rendered by stage one. The name
+// doesn't depend on the preset, stage two can run again with another
+// encoder.
+func (j *Job) LosslessPath() string {
+	return j.WorkPath(".lossless.avi")
+}
+
+// stageKeyPath returns the file storing the key of the rendered intermediate.
+func (j *Job) stageKeyPath() string {
+	return j.WorkPath(".lossless.key")
+}
+
+// LosslessArgs returns the ffmpeg arguments rendering the filtered video
+// into the lossless intermediate. video are the input arguments of the
+// video rendered by a frameserver, nil reads the source.
+func LosslessArgs(j *Job, video []string) []string {
+	args := append([]string{"-y"}, videoInputArgs(j, video)...)
+	args = append(args, "-c:v", j.Intermediate.FFmpegCodec(), "-an", "-sn")
+	return append(args, j.LosslessPath())
+}
+
+// stageOneKey returns the hash of everything changing the intermediate: the
+// backend, the codec, the source and the scripts and arguments rendering it.
+func stageOneKey(j *Job, backend string, scripts []string, args []string) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, backend, j.Intermediate, j.Source.FullPath, j.Source.Size)
+	for _, script := range scripts {
+		content, err := os.ReadFile(script)
+		if err != nil {
+			return "", err
+		}
+		h.Write(content)
+	}
+	fmt.Fprintln(h, strings.Join(args, " "))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// renderStageOne renders the lossless intermediate, unless the intermediate
+// of an earlier run with the same key exists. The key is written after the
+// render succeeded, an aborted render is never reused.
+func renderStageOne(j *Job, key string, render func() error, report func(Progress)) error {
+	j.Workspace.Track(j.LosslessPath())
+	keyPath := j.Workspace.Track(j.stageKeyPath())
+	if stored, err := os.ReadFile(keyPath); err == nil && string(stored) == key {
+		if _, err := os.Stat(j.LosslessPath()); err == nil {
+			if report != nil {
+				report(Progress{Stage: "stage 1 reused", Percent: 100})
+			}
+			return nil
+		}
+	}
+
+	if err := os.Remove(keyPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := render(); err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, []byte(key), 0o644)
+}
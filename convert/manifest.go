@@ -0,0 +1,153 @@
+package convert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+	"gopkg.in/yaml.v2"
+)
+
+// ManifestFile is the name of the manifest in the output directory.
+const ManifestFile = "videoconverter-manifest.yaml"
+
+// ManifestEntry records the conversion of a source into an output file.
+type ManifestEntry struct {
+	Source       string            `yaml:"source"`          // path of the source at the time of the conversion
+	SourceHash   string            `yaml:"source_hash"`     // fingerprint of the source, see util.FingerprintFile
+	SettingsHash string            `yaml:"settings_hash"`   // hash of the settings changing the output
+	Backend      string            `yaml:"backend"`         // backend of the conversion, part of the settings hash
+	Tools        map[string]string `yaml:"tools,omitempty"` // versions of the programs used
+	Converted    time.Time         `yaml:"converted"`       // end of the conversion
+}
+
+// Manifest lists the converted files of an output directory, keyed by the
+// name of the output file. A file whose source, settings and tools didn't
+// change since the conversion doesn't need to be converted again.
+type Manifest struct {
+	Outputs map[string]ManifestEntry `yaml:"outputs"`
+}
+
+// LoadManifest reads the manifest of the output directory. A missing
+// manifest is empty.
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{Outputs: map[string]ManifestEntry{}}
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return m, fmt.Errorf("%s: %w", ManifestFile, err)
+	}
+	if m.Outputs == nil {
+		m.Outputs = map[string]ManifestEntry{}
+	}
+	return m, nil
+}
+
+// Save writes the manifest into the output directory.
+func (m *Manifest) Save(dir string) error {
+	data, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0o644)
+}
+
+// NewManifestEntry returns the entry of the job converted by the backend
+// with the hashes of the source and the settings and the versions of the
+// tools, see ToolVersions. The target size has to be applied before, the
+// bit rate of the preset is part of the settings.
+func NewManifestEntry(j *Job, backend string, tools map[string]string) (ManifestEntry, error) {
+	fp, err := util.FingerprintFile(j.Source.FullPath)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{
+		Source:       j.Source.FullPath,
+		SourceHash:   fp.String(),
+		SettingsHash: settingsHash(j, backend),
+		Backend:      backend,
+		Tools:        tools,
+	}, nil
+}
+
+// settingsHash returns the hash of the settings changing the output. The
+// backend is part of the settings, VirtualDub2 and ffmpeg encode with
+// different codecs. The verification and the metrics don't change the
+// output.
+func settingsHash(j *Job, backend string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", backend)
+	fmt.Fprintf(h, "%v\n%v %v %v\n", j.Preset, j.Frameserver, j.Container, j.Intermediate)
+	fmt.Fprintf(h, "%v %v %v\n", j.Audio, j.AudioTracks, j.AudioDelay)
+	fmt.Fprintf(h, "%v %v\n", j.Subtitles, j.Chapter)
+	fmt.Fprintf(h, "%v %v %d %d %d\n", j.TrimStart, j.TrimEnd, j.Width, j.Height, j.TargetSize)
+	fmt.Fprintf(h, "%v\n", j.Filters)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UpToDate returns true if the output of the job exists and was converted
+// from the same source with the same settings and the same tool versions
+// as the entry. An update of ffmpeg or the frameserver converts again.
+func (m *Manifest) UpToDate(j *Job, e ManifestEntry) bool {
+	done, ok := m.Outputs[filepath.Base(j.OutputPath())]
+	if !ok || done.SourceHash != e.SourceHash || done.SettingsHash != e.SettingsHash {
+		return false
+	}
+	if !maps.Equal(done.Tools, e.Tools) {
+		return false
+	}
+	_, err := os.Stat(j.OutputPath())
+	return err == nil
+}
+
+// Record adds the converted job to the manifest in the output directory.
+// The manifest is read again, other conversions into the directory are
+// kept.
+func Record(j *Job, e ManifestEntry) error {
+	m, err := LoadManifest(j.OutputDir)
+	if err != nil {
+		return err
+	}
+	e.Converted = time.Now()
+	m.Outputs[filepath.Base(j.OutputPath())] = e
+	return m.Save(j.OutputDir)
+}
+
+// ToolVersions returns the versions of the programs used by the backend
+// and the frameserver, for the manifest. VirtualDub2 doesn't report its
+// version, the path is recorded.
+func ToolVersions(ctx context.Context, t Tools, backend string, fs Frameserver) map[string]string {
+	versions := map[string]string{}
+	add := func(c ToolCheck) {
+		if c.OK() && c.Version != "" {
+			versions[c.Name] = c.Version
+		}
+	}
+	add(checkVersion(ctx, "ffmpeg", t.FFmpeg, true, "-version"))
+	if backend == BackendVirtualDub {
+		versions["VirtualDub2"] = t.VirtualDub
+		if fs == FrameserverAviSynth {
+			add(checkAviSynth(ctx, t.FFmpeg))
+		}
+	}
+	if fs == FrameserverVapourSynth {
+		add(checkVersion(ctx, "vspipe", t.VSPipe, true, "--version"))
+	}
+	if t.Wine.Enabled() && backend == BackendVirtualDub {
+		add(checkVersion(ctx, "Wine", t.Wine.Launcher, true, "--version"))
+	}
+	return versions
+}
@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+func TestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "clip.avi")
+	if err := os.WriteFile(source, []byte("capture"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	newJob := func() *Job {
+		return &Job{
+			Source:    &util.MediaInfo{Name: "clip.avi", FullPath: source},
+			Container: ContainerMKV,
+			OutputDir: filepath.Join(dir, "out"),
+		}
+	}
+	j := newJob()
+	if err := os.Mkdir(j.OutputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tools := map[string]string{"ffmpeg": "7.1"}
+	entry, err := NewManifestEntry(j, BackendFFmpeg, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Record(j, entry); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadManifest(j.OutputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.UpToDate(j, entry) {
+		t.Error("up to date without output file")
+	}
+	if err := os.WriteFile(j.OutputPath(), []byte("output"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		backend string
+		tools   map[string]string
+		change  func(j *Job)
+		want    bool
+	}{
+		{"unchanged", BackendFFmpeg, tools, nil, true},
+		{"other backend", BackendVirtualDub, tools, nil, false},
+		{"updated ffmpeg", BackendFFmpeg, map[string]string{"ffmpeg": "7.2"}, nil, false},
+		{"additional tool", BackendFFmpeg, map[string]string{"ffmpeg": "7.1", "vspipe": "R70"}, nil, false},
+		{"other settings", BackendFFmpeg, tools, func(j *Job) { j.Width = 1280 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newJob()
+			if tt.change != nil {
+				tt.change(j)
+			}
+			e, err := NewManifestEntry(j, tt.backend, tt.tools)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.UpToDate(j, e); got != tt.want {
+				t.Errorf("UpToDate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// a changed source is converted again
+	if err := os.WriteFile(source, []byte("new capture"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewManifestEntry(j, BackendFFmpeg, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.UpToDate(j, e) {
+		t.Error("up to date with changed source")
+	}
+}
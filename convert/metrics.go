@@ -0,0 +1,222 @@
+package convert
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Metrics holds the quality scores of an encode compared to the filtered
+// reference.
+type Metrics struct {
+	PSNR float64 // average PSNR in dB
+	SSIM float64 // SSIM of all planes, 0..1
+	VMAF float64 // VMAF score 0..100, -1 without libvmaf
+}
+
+// String returns the scores for display like "VMAF 95.1, SSIM 0.987, PSNR 42.3 dB".
+func (m Metrics) String() string {
+	s := fmt.Sprintf("SSIM %.3f, PSNR %.1f dB", m.SSIM, m.PSNR)
+	if m.VMAF >= 0 {
+		s = fmt.Sprintf("VMAF %.1f, %s", m.VMAF, s)
+	}
+	return s
+}
+
+// Summary returns the main score for a short display, VMAF or else PSNR.
+func (m Metrics) Summary() string {
+	if m.VMAF >= 0 {
+		return fmt.Sprintf("VMAF %.1f", m.VMAF)
+	}
+	return fmt.Sprintf("PSNR %.1f dB", m.PSNR)
+}
+
+// referenceInput returns the input arguments of the filtered reference and
+// the VapourSynth script rendering it. The reference is rendered like the
+// encode of the backend: the lossless intermediate, the VapourSynth script,
+// the AviSynth+ script of VirtualDub2 or the source filtered, scaled and
+// trimmed like by the ffmpeg backend. nil reads the source.
+func referenceInput(j *Job, backend string) (video []string, script string, err error) {
+	switch {
+	case j.Intermediate != LosslessNone:
+		return []string{"-i", j.LosslessPath()}, "", nil
+	case j.Frameserver == FrameserverVapourSynth:
+		return []string{"-f", "yuv4mpegpipe", "-i", "-"}, j.WorkPath(".vpy"), nil
+	case backend == BackendVirtualDub:
+		// the ffmpeg filters differ from the AviSynth+ filters like QTGMC,
+		// only the script itself is a valid reference
+		if len(j.Paths) > 0 {
+			return nil, "", errors.New("the AviSynth+ script has paths translated for Wine or another system " +
+				"and can't be read by ffmpeg, use a two-stage render for the metrics")
+		}
+		return []string{"-f", "avisynth", "-i", j.WorkPath(".avs")}, "", nil
+	}
+	return nil, "", nil
+}
+
+// MetricsArgs returns the ffmpeg arguments comparing the output with the
+// reference with the psnr and ssim filters and libvmaf if vmaf is set.
+// video are the input arguments of the reference, nil reads the source.
+// Both videos are converted to the pixel format of the preset.
+func MetricsArgs(j *Job, video []string, vmaf bool) []string {
+	args := []string{"-y", "-i", j.OutputPath()}
+	ref := "[1:v]"
+	if video != nil {
+		args = append(args, video...)
+	} else {
+		args = append(args, j.seekArgs()...)
+		args = append(args, "-i", j.Source.FullPath)
+		if v := j.Source.FirstVideo(); v != nil {
+			ref = fmt.Sprintf("[1:%d]", v.Index)
+		}
+		for _, f := range videoFilters(j) {
+			ref += f + ","
+		}
+	}
+
+	format := j.Preset.PixelFormat
+	if format == "" {
+		format = "yuv420p"
+	}
+	metrics := []string{"psnr", "ssim"}
+	if vmaf {
+		metrics = append(metrics, "libvmaf")
+	}
+	n := len(metrics)
+	graph := []string{
+		fmt.Sprintf("[0:v]setpts=PTS-STARTPTS,format=%s,split=%d%s", format, n, labels("d", n)),
+		fmt.Sprintf("%ssetpts=PTS-STARTPTS,format=%s,split=%d%s", ref, format, n, labels("r", n)),
+	}
+	var maps []string
+	for i, m := range metrics {
+		// the encode is the first input, libvmaf expects it as main input
+		graph = append(graph, fmt.Sprintf("[d%d][r%d]%s[m%d]", i, i, m, i))
+		maps = append(maps, "-map", fmt.Sprintf("[m%d]", i))
+	}
+	args = append(args, "-filter_complex", strings.Join(graph, ";"))
+	args = append(args, maps...)
+	return append(args, "-f", "null", "-")
+}
+
+// labels returns n numbered filter pad labels like "[d0][d1]".
+func labels(prefix string, n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "[%s%d]", prefix, i)
+	}
+	return b.String()
+}
+
+var (
+	psnrAverage = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	ssimAll     = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+	vmafScore   = regexp.MustCompile(`VMAF score[:=]\s*([0-9.]+)`)
+)
+
+// ParseMetrics returns the scores printed by the metric filters. The VMAF
+// score is -1 if libvmaf didn't run.
+func ParseMetrics(output string) (Metrics, error) {
+	m := Metrics{VMAF: -1}
+	found := false
+	for _, line := range strings.Split(output, "\n") {
+		if s := psnrAverage.FindStringSubmatch(line); s != nil {
+			m.PSNR, _ = strconv.ParseFloat(s[1], 64)
+			found = true
+		}
+		if s := ssimAll.FindStringSubmatch(line); s != nil {
+			m.SSIM, _ = strconv.ParseFloat(s[1], 64)
+			found = true
+		}
+		if s := vmafScore.FindStringSubmatch(line); s != nil {
+			m.VMAF, _ = strconv.ParseFloat(s[1], 64)
+		}
+	}
+	if !found {
+		return m, fmt.Errorf("no scores in the ffmpeg output: %s", lastLines(output, 3))
+	}
+	return m, nil
+}
+
+// HasFilter returns true if ffmpeg has the filter, like "libvmaf" which is
+// missing in many builds.
+func HasFilter(ctx context.Context, ffmpeg, filter string) bool {
+	out, err := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-filters").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[1] == filter {
+			return true
+		}
+	}
+	return false
+}
+
+// analyze compares the output of the job with the filtered reference and
+// stores the scores in the job.
+func analyze(ctx context.Context, t Tools, backend string, j *Job, report func(Progress)) error {
+	if !j.Analyze {
+		return nil
+	}
+	video, script, err := referenceInput(j, backend)
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	args := MetricsArgs(j, video, HasFilter(ctx, t.FFmpeg, "libvmaf"))
+
+	var output string
+	if script != "" {
+		output, err = runVSPipe(ctx, t, script, args, "metrics", j.OutputDuration(), report)
+	} else {
+		output, err = runFFmpegInput(ctx, t.FFmpeg, args, nil, "metrics", j.OutputDuration(), report)
+	}
+	if err != nil && slices.Contains(video, "avisynth") {
+		return fmt.Errorf("metrics need ffmpeg with AviSynth+ support to read the script: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+	m, err := ParseMetrics(output)
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	j.Scores = &m
+	return nil
+}
+
+// WriteMetricsCSV writes the scores of the analysed jobs as CSV file.
+func WriteMetricsCSV(path string, jobs []*Job) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"source", "output", "preset", "psnr", "ssim", "vmaf"})
+	for _, j := range jobs {
+		if j.Scores == nil {
+			continue
+		}
+		vmaf := ""
+		if j.Scores.VMAF >= 0 {
+			vmaf = strconv.FormatFloat(j.Scores.VMAF, 'f', 3, 64)
+		}
+		w.Write([]string{j.Source.FullPath, j.OutputPath(), j.Preset.Name,
+			strconv.FormatFloat(j.Scores.PSNR, 'f', 3, 64),
+			strconv.FormatFloat(j.Scores.SSIM, 'f', 6, 64),
+			vmaf})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,164 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ffmpegAudioCodec returns the ffmpeg encoder of the audio codec.
+func ffmpegAudioCodec(codec AudioCodec, c Container) string {
+	switch codec {
+	case AudioAAC:
+		return "aac"
+	case AudioOpus:
+		return "libopus"
+	case AudioFLAC:
+		return "flac"
+	case AudioPCM:
+		if c == ContainerMOV {
+			return "pcm_s16be"
+		}
+		return "pcm_s16le"
+	}
+	return "copy"
+}
+
+// audioArgs returns the ffmpeg arguments encoding all audio tracks with the
+// codec of the project. The language of the source tracks is kept.
+func audioArgs(j *Job) []string {
+	tracks := j.SelectedAudio()
+	if len(tracks) == 0 {
+		return []string{"-an"}
+	}
+
+	args := []string{"-c:a", ffmpegAudioCodec(j.Audio.Codec, j.Container)}
+	if !j.Audio.Codec.Lossless() {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", j.Audio.Bitrate))
+	}
+	for i, a := range tracks {
+		if a.Language != "" {
+			args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), "language="+a.Language)
+		}
+	}
+	return args
+}
+
+// MuxArgs returns the ffmpeg arguments muxing the intermediate AVI written
+// by VirtualDub2 and the audio scripts of the additional tracks into the
+// output container. The video is copied, or encoded with the preset from
+// the lossless intermediate in two-stage mode. The audio is encoded with
+// the codec of the project, subtitles are muxed from the source and
+// chapters from the chapter file.
+func MuxArgs(j *Job, s *Scripts) []string {
+	args := []string{"-y", "-i", j.IntermediatePath()}
+	for _, script := range s.AudioTracks {
+		args = append(args, "-i", script)
+	}
+	// VapourSynth renders no audio, it is read from the source
+	if j.Frameserver == FrameserverVapourSynth {
+		args = append(args, j.seekArgs()...)
+		args = append(args, "-i", j.Source.FullPath)
+	}
+	inputs := countInputs(args)
+	subInputs, subMaps := subtitleMuxArgs(j, inputs)
+	args = append(args, subInputs...)
+	chapterInputs, chapterMaps := chapterMuxArgs(j, s.Chapters, inputs+countInputs(subInputs))
+	args = append(args, chapterInputs...)
+
+	args = append(args, "-map", "0:v")
+	if j.Frameserver == FrameserverVapourSynth {
+		args = append(args, sourceAudioArgs(j, 1)...)
+	} else if len(j.SelectedAudio()) > 0 {
+		args = append(args, "-map", "0:a")
+	}
+	for i := range s.AudioTracks {
+		args = append(args, "-map", strconv.Itoa(i+1)+":a")
+	}
+
+	if j.Intermediate != LosslessNone {
+		args = append(args, encodeArgs(j)...)
+	} else {
+		args = append(args, "-c:v", "copy")
+	}
+	args = append(args, audioArgs(j)...)
+	args = append(args, subMaps...)
+	args = append(args, chapterMaps...)
+	return append(args, j.OutputPath())
+}
+
+// Scripts holds the paths of the scripts written for a job.
+type Scripts struct {
+	AviSynth    string   // AviSynth+ script with video and the first audio track
+	AudioTracks []string // audio only scripts of the additional audio tracks
+	VirtualDub  string   // VirtualDub2 script rendering the AviSynth+ script
+	VapourSynth string   // VapourSynth script instead of the AviSynth+ scripts
+	Chapters    string   // ffmetadata chapter file, empty without chapters
+	OGMChapters string   // the same chapters in OGM format
+}
+
+// Source returns the frameserver script loading the source.
+func (s *Scripts) Source() string {
+	if s.VapourSynth != "" {
+		return s.VapourSynth
+	}
+	return s.AviSynth
+}
+
+// countInputs returns the number of "-i" inputs in the arguments.
+func countInputs(args []string) int {
+	n := 0
+	for _, arg := range args {
+		if arg == "-i" {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteScripts validates the job and writes all of its scripts into the
+// work directory.
+func WriteScripts(j *Job, t Tools) (*Scripts, error) {
+	if err := j.Audio.Validate(j.Container); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(j.WorkDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create work directory: %w", err)
+	}
+
+	s := &Scripts{VirtualDub: j.WorkPath(".vcf")}
+	j.Workspace.Track(j.IndexPath())
+	var source string
+	if j.Frameserver == FrameserverVapourSynth {
+		s.VapourSynth = j.WorkPath(".vpy")
+		source = s.VapourSynth
+		if err := j.writeWorkFile(s.VapourSynth, VapourSynthScript(j, t.VapourSynthPlugins)); err != nil {
+			return nil, err
+		}
+	} else {
+		s.AviSynth = j.WorkPath(".avs")
+		source = s.AviSynth
+		if err := j.writeWorkFile(s.AviSynth, AviSynthScript(j, t.AviSynthPlugins)); err != nil {
+			return nil, err
+		}
+
+		tracks := j.SelectedAudio()
+		for i := 1; i < len(tracks); i++ {
+			path := j.WorkPath(fmt.Sprintf(".audio%d.avs", i+1))
+			if err := j.writeWorkFile(path, AudioTrackScript(j, tracks[i], t.AviSynthPlugins)); err != nil {
+				return nil, err
+			}
+			s.AudioTracks = append(s.AudioTracks, path)
+		}
+	}
+
+	if err := j.writeWorkFile(s.VirtualDub, VirtualDubScript(j, source)); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if s.Chapters, s.OGMChapters, err = writeChapters(j); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,129 @@
+package convert
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// PathMapping replaces the prefix From of a path with To, like
+// "/mnt/videos" with `\\nas\videos` or "/" with `Z:\` for Wine.
+type PathMapping struct {
+	From string // directory on this computer
+	To   string // the same directory for the program reading the script
+}
+
+// PathMap translates the paths written into scripts for programs running
+// on another computer, another system or under Wine. The mapping with the
+// longest matching directory is used, paths without a mapping are kept.
+type PathMap []PathMapping
+
+// wineRoot maps the root directory to the Z: drive of Wine.
+var wineRoot = PathMapping{From: "/", To: `Z:\`}
+
+// ParsePathMappings reads mappings written as "from=to", separated by
+// ";", like "/mnt/videos=\\nas\videos;/=Z:\".
+func ParsePathMappings(s string) (PathMap, error) {
+	var m PathMap
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(entry, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("path mapping %q is not from=to", entry)
+		}
+		m = append(m, PathMapping{From: from, To: to})
+	}
+	return m, nil
+}
+
+// String returns the mappings in the format of ParsePathMappings.
+func (m PathMap) String() string {
+	entries := make([]string, len(m))
+	for i, mp := range m {
+		entries[i] = mp.From + "=" + mp.To
+	}
+	return strings.Join(entries, ";")
+}
+
+// Translate returns the path for the program reading the script. The path
+// is made absolute before matching the prefixes. Paths mapped to a drive
+// letter or a UNC path get backslashes.
+func (m PathMap) Translate(path string) string {
+	if path == "" || len(m) == 0 {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	slash := filepath.ToSlash(path)
+	best, rest, found := PathMapping{}, "", false
+	for _, mp := range m {
+		r, ok := cutPathPrefix(slash, filepath.ToSlash(mp.From))
+		if ok && (!found || len(r) < len(rest)) {
+			best, rest, found = mp, r, true
+		}
+	}
+	switch {
+	case !found:
+		return path
+	case rest == "":
+		return best.To
+	}
+	to := strings.TrimRight(best.To, `/\`)
+	if isWindowsPath(best.To) {
+		return to + `\` + strings.ReplaceAll(rest, "/", `\`)
+	}
+	return to + "/" + rest
+}
+
+// cutPathPrefix returns the path after the directory prefix, matching whole
+// directory names only. Windows paths are compared case-insensitively.
+func cutPathPrefix(path, prefix string) (string, bool) {
+	prefix = strings.TrimRight(prefix, "/")
+	if len(path) < len(prefix) {
+		return "", false
+	}
+	head := path[:len(prefix)]
+	if head != prefix && !(runtime.GOOS == "windows" && strings.EqualFold(head, prefix)) {
+		return "", false
+	}
+	rest := path[len(prefix):]
+	switch {
+	case rest == "":
+		return "", true
+	case rest[0] == '/':
+		return rest[1:], true
+	}
+	return "", false
+}
+
+// isWindowsPath returns true for paths starting with a drive letter like
+// "Z:" or for UNC paths like `\\server\share`.
+func isWindowsPath(path string) bool {
+	if strings.HasPrefix(path, `\\`) {
+		return true
+	}
+	return len(path) >= 2 && path[1] == ':' &&
+		(path[0] >= 'A' && path[0] <= 'Z' || path[0] >= 'a' && path[0] <= 'z')
+}
+
+// scriptPaths returns the path map of the scripts read by VirtualDub2 and
+// AviSynth+: the configured mappings, and the Z: drive under Wine.
+func (t Tools) scriptPaths() PathMap {
+	m := t.PathMappings
+	if t.Wine.Enabled() {
+		m = append(slices.Clone(m), wineRoot)
+	}
+	return m
+}
+
+// scriptPath returns the path as written into the scripts read by
+// VirtualDub2 and AviSynth+.
+func (j *Job) scriptPath(path string) string {
+	return j.Paths.Translate(path)
+}
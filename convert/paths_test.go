@@ -0,0 +1,187 @@
+package convert
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// skipWindows skips tests with POSIX paths, they are relative on Windows.
+func skipWindows(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses POSIX paths")
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	skipWindows(t)
+	nas := PathMapping{From: "/mnt/videos", To: `\\nas\videos`}
+	tests := []struct {
+		name string
+		m    PathMap
+		path string
+		want string
+	}{
+		{"no mappings", nil, "/mnt/videos/a.mkv", "/mnt/videos/a.mkv"},
+		{"empty path", PathMap{nas}, "", ""},
+		{"prefix", PathMap{nas}, "/mnt/videos/2024/a.mkv", `\\nas\videos\2024\a.mkv`},
+		{"directory itself", PathMap{nas}, "/mnt/videos", `\\nas\videos`},
+		{"whole directory names only", PathMap{nas}, "/mnt/videos2/a.mkv", "/mnt/videos2/a.mkv"},
+		{"unmapped path", PathMap{nas}, "/home/user/a.mkv", "/home/user/a.mkv"},
+		{"cleaned path", PathMap{nas}, "/mnt/videos/../videos/./a.mkv", `\\nas\videos\a.mkv`},
+		{"trailing slash of from", PathMap{{"/mnt/videos/", `V:\`}}, "/mnt/videos/a.mkv", `V:\a.mkv`},
+		{"drive without backslash", PathMap{{"/mnt/videos", "V:"}}, "/mnt/videos/a.mkv", `V:\a.mkv`},
+		{"lower case drive", PathMap{{"/mnt/videos", `v:\`}}, "/mnt/videos/a.mkv", `v:\a.mkv`},
+		{"unc share root", PathMap{{"/mnt", `\\nas\share\`}}, "/mnt/a/b.mkv", `\\nas\share\a\b.mkv`},
+		{"posix target", PathMap{{"/home/user", "/Volumes/user/"}}, "/home/user/a.mkv", "/Volumes/user/a.mkv"},
+		{"posix root target", PathMap{{"/home/user", "/"}}, "/home/user/a.mkv", "/a.mkv"},
+		{"longest prefix", PathMap{wineRoot, nas}, "/mnt/videos/a.mkv", `\\nas\videos\a.mkv`},
+		{"longest prefix in any order", PathMap{nas, wineRoot}, "/mnt/videos/a.mkv", `\\nas\videos\a.mkv`},
+		{"shorter prefix", PathMap{nas, wineRoot}, "/mnt/music/a.mkv", `Z:\mnt\music\a.mkv`},
+		{"nested mappings", PathMap{nas, {"/mnt/videos/raw", `R:\`}}, "/mnt/videos/raw/a.mkv", `R:\a.mkv`},
+		// Linux file names are case-sensitive, Windows paths are compared case-insensitively
+		{"case-sensitive", PathMap{nas}, "/mnt/Videos/a.mkv", "/mnt/Videos/a.mkv"},
+		{"case of the rest kept", PathMap{nas}, "/mnt/videos/Clip.MKV", `\\nas\videos\Clip.MKV`},
+		{"root", PathMap{wineRoot}, "/", `Z:\`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.m.Translate(tt.path); got != tt.want {
+				t.Errorf("Translate(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutPathPrefix(t *testing.T) {
+	windows, foldedRest := runtime.GOOS == "windows", ""
+	if windows {
+		foldedRest = "a.mkv"
+	}
+	tests := []struct {
+		path, prefix string
+		rest         string
+		ok           bool
+	}{
+		{"/mnt/videos/a.mkv", "/mnt/videos", "a.mkv", true},
+		{"/mnt/videos/a.mkv", "/mnt/videos/", "a.mkv", true},
+		{"/mnt/videos", "/mnt/videos", "", true},
+		{"/mnt/videos2", "/mnt/videos", "", false},
+		{"/mnt", "/mnt/videos", "", false},
+		{"/a.mkv", "/", "a.mkv", true},
+		// Windows paths are compared case-insensitively
+		{"C:/Videos/a.mkv", "c:/videos", foldedRest, windows},
+	}
+	for _, tt := range tests {
+		rest, ok := cutPathPrefix(tt.path, tt.prefix)
+		if rest != tt.rest || ok != tt.ok {
+			t.Errorf("cutPathPrefix(%q, %q) = %q, %v, want %q, %v", tt.path, tt.prefix, rest, ok, tt.rest, tt.ok)
+		}
+	}
+}
+
+func TestScriptPaths(t *testing.T) {
+	skipWindows(t)
+	nas := PathMapping{From: "/mnt/videos", To: `\\nas\videos`}
+	tests := []struct {
+		name  string
+		tools Tools
+		path  string
+		want  string
+	}{
+		{"native", Tools{}, "/home/user/a.mkv", "/home/user/a.mkv"},
+		{"native with mapping", Tools{PathMappings: PathMap{nas}}, "/home/user/a.mkv", "/home/user/a.mkv"},
+		{"wine", Tools{Wine: Wine{Launcher: "wine"}}, "/home/user/a.mkv", `Z:\home\user\a.mkv`},
+		{"wine with mapping", Tools{Wine: Wine{Launcher: "wine"}, PathMappings: PathMap{nas}}, "/mnt/videos/a.mkv", `\\nas\videos\a.mkv`},
+		{"wine falls back to Z:", Tools{Wine: Wine{Launcher: "wine"}, PathMappings: PathMap{nas}}, "/home/user/a.mkv", `Z:\home\user\a.mkv`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{Paths: tt.tools.scriptPaths()}
+			if got := j.scriptPath(tt.path); got != tt.want {
+				t.Errorf("scriptPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	// the configured mappings are not changed by the Z: drive
+	tools := Tools{Wine: Wine{Launcher: "wine"}, PathMappings: make(PathMap, 1, 2)}
+	tools.PathMappings[0] = nas
+	tools.scriptPaths()
+	if got := tools.PathMappings[:2]; got[1] != (PathMapping{}) {
+		t.Errorf("scriptPaths changed the configured mappings: %v", got)
+	}
+}
+
+func TestScriptStrings(t *testing.T) {
+	skipWindows(t)
+	m := PathMap{wineRoot}
+	tests := []struct {
+		name    string
+		path    string
+		avs, vd string
+	}{
+		{"spaces", "/tmp/my clip.mkv", `"Z:\tmp\my clip.mkv"`, `"Z:\\tmp\\my clip.mkv"`},
+		{"double quotes", `/tmp/say "hi".mkv`, `e"Z:\\tmp\\say \"hi\".mkv"`, `"Z:\\tmp\\say \"hi\".mkv"`},
+		{"single quote", "/tmp/it's.mkv", `"Z:\tmp\it's.mkv"`, `"Z:\\tmp\\it's.mkv"`},
+		{"umlaut", "/tmp/Grüße.mkv", `"Z:\tmp\Grüße.mkv"`, `U"Z:\\tmp\\Grüße.mkv"`},
+		{"cjk", "/tmp/動画 1.mkv", `"Z:\tmp\動画 1.mkv"`, `U"Z:\\tmp\\動画 1.mkv"`},
+		{"tab", "/tmp/a\tb.mkv", `e"Z:\\tmp\\a\tb.mkv"`, `"Z:\\tmp\\a\x09b.mkv"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := m.Translate(tt.path)
+			if got := avsString(path); got != tt.avs {
+				t.Errorf("avsString(%q) = %s, want %s", path, got, tt.avs)
+			}
+			if got := vdString(path); got != tt.vd {
+				t.Errorf("vdString(%q) = %s, want %s", path, got, tt.vd)
+			}
+		})
+	}
+}
+
+func TestParsePathMappings(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want PathMap
+	}{
+		{"empty", "", nil},
+		{"blank entries", " ; ;", nil},
+		{"one", `/mnt/videos=\\nas\videos`, PathMap{{"/mnt/videos", `\\nas\videos`}}},
+		{"spaces trimmed", ` /mnt/videos = \\nas\videos ; /=Z:\ `, PathMap{{"/mnt/videos", `\\nas\videos`}, {"/", `Z:\`}}},
+		{"spaces in paths", `/mnt/my videos=V:\my videos`, PathMap{{"/mnt/my videos", `V:\my videos`}}},
+		{"equals sign in target", "/a=/b=c", PathMap{{"/a", "/b=c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePathMappings(tt.in)
+			if err != nil {
+				t.Fatalf("ParsePathMappings(%q): %v", tt.in, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParsePathMappings(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			if again, err := ParsePathMappings(got.String()); err != nil || !slices.Equal(again, got) {
+				t.Errorf("ParsePathMappings(%q) = %v, %v, want %v", got.String(), again, err, got)
+			}
+		})
+	}
+}
+
+func TestParsePathMappingsErrors(t *testing.T) {
+	for _, in := range []string{
+		"/mnt/videos",
+		`=Z:\`,
+		"/mnt/videos=",
+		" = ",
+		`/mnt/videos=V:\;broken`,
+		"/mnt/videos=\t",
+	} {
+		if m, err := ParsePathMappings(in); err == nil {
+			t.Errorf("ParsePathMappings(%q) = %v, want error", in, m)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// PluginDef describes an AviSynth+ plugin, a DLL or an autoloaded .avsi
+// script, and the plugins it needs.
+type PluginDef struct {
+	Name     string   // name used in the filter definitions
+	Files    []string // lower case file names without extension
+	Requires []string // plugins called by this plugin
+}
+
+// knownPlugins are the plugins used by the scripts and the filters.
+var knownPlugins = []PluginDef{
+	{Name: "LSMASHSource", Files: []string{"lsmashsource"}},
+	{Name: "QTGMC", Files: []string{"qtgmc"}, Requires: []string{"MVTools2", "masktools2", "RgTools", "nnedi3"}},
+	{Name: "MVTools2", Files: []string{"mvtools2", "libmvtools2"}},
+	{Name: "masktools2", Files: []string{"masktools2"}},
+	{Name: "RgTools", Files: []string{"rgtools"}},
+	{Name: "nnedi3", Files: []string{"nnedi3"}},
+	{Name: "FFT3DFilter", Files: []string{"fft3dfilter"}},
+	{Name: "Deblock", Files: []string{"deblock"}},
+	{Name: "CAS", Files: []string{"cas"}},
+	{Name: "ColorMatrix", Files: []string{"colormatrix"}},
+}
+
+// lookupPlugin returns the definition of the plugin with the name.
+func lookupPlugin(name string) (PluginDef, bool) {
+	for _, p := range knownPlugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PluginDef{}, false
+}
+
+// PluginInventory lists the plugin files found in a plugin directory.
+type PluginInventory struct {
+	Dir   string
+	files map[string]string // normalized file name -> path
+}
+
+// ScanPlugins returns the DLLs and .avsi scripts in the plugin directory,
+// like AviSynth+ autoloads them. Subdirectories are not scanned.
+func ScanPlugins(dir string) (*PluginInventory, error) {
+	inv := &PluginInventory{Dir: dir, files: map[string]string{}}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return inv, err
+	}
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".dll" && ext != ".avsi") {
+			continue
+		}
+		inv.files[pluginKey(e.Name())] = filepath.Join(dir, e.Name())
+	}
+	return inv, nil
+}
+
+// pluginKey returns the file name in lower case without extension and
+// architecture suffix, "MVTools2_x64.dll" is "mvtools2".
+func pluginKey(name string) string {
+	key := strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+	for _, suffix := range []string{"_x64", "_x86", "-x64", "-x86"} {
+		key = strings.TrimSuffix(key, suffix)
+	}
+	return key
+}
+
+// Files returns the paths of all plugin files found.
+func (inv *PluginInventory) Files() []string {
+	var files []string
+	for _, path := range inv.files {
+		files = append(files, path)
+	}
+	slices.Sort(files)
+	return files
+}
+
+// Path returns the file of the plugin, empty if it is missing. Unknown
+// plugins are looked up by their name.
+func (inv *PluginInventory) Path(plugin string) string {
+	files := []string{strings.ToLower(plugin)}
+	if def, ok := lookupPlugin(plugin); ok {
+		files = def.Files
+	}
+	for _, f := range files {
+		if path, ok := inv.files[f]; ok {
+			return path
+		}
+	}
+	return ""
+}
+
+// Missing returns the plugins without a file.
+func (inv *PluginInventory) Missing(plugins []string) []string {
+	var missing []string
+	for _, p := range plugins {
+		if inv.Path(p) == "" {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// Filters returns the names of the filters whose plugins are all present.
+func (inv *PluginInventory) Filters() []string {
+	var names []string
+	for _, d := range filterDefs {
+		if len(inv.Missing(withDependencies(d.Plugins))) == 0 {
+			names = append(names, d.Name)
+		}
+	}
+	return names
+}
+
+// withDependencies returns the plugins and the plugins they need, each
+// plugin once.
+func withDependencies(plugins []string) []string {
+	var all []string
+	var add func(names []string)
+	add = func(names []string) {
+		for _, name := range names {
+			if slices.Contains(all, name) {
+				continue
+			}
+			all = append(all, name)
+			if def, ok := lookupPlugin(name); ok {
+				add(def.Requires)
+			}
+		}
+	}
+	add(plugins)
+	return all
+}
+
+// RequiredPlugins returns the AviSynth+ plugins loaded by the script of the
+// job, the source filter and the plugins of the enabled filters with their
+// dependencies.
+func RequiredPlugins(j *Job) []string {
+	plugins := []string{"LSMASHSource"}
+	j.Filters.active(func(d *FilterDef, v FilterValues) {
+		plugins = append(plugins, d.Plugins...)
+	})
+	return withDependencies(plugins)
+}
+
+// CheckPlugins returns an error listing the plugins of the job missing in
+// the plugin directory. Without a directory the plugins are autoloaded from
+// the default directories of AviSynth+, which are not checked.
+func CheckPlugins(j *Job, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	inv, err := ScanPlugins(dir)
+	if err != nil {
+		return fmt.Errorf("AviSynth+ plugins: %w", err)
+	}
+	if missing := inv.Missing(RequiredPlugins(j)); len(missing) > 0 {
+		return fmt.Errorf("AviSynth+ plugins missing in %s: %s", dir, strings.Join(missing, ", "))
+	}
+	return nil
+}
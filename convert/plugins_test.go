@@ -0,0 +1,93 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRequiredPlugins(t *testing.T) {
+	disabled := NewFilter("Denoise")
+	disabled.Enabled = false
+	tests := []struct {
+		name    string
+		filters FilterChain
+		want    []string
+	}{
+		{"no filters", nil, []string{"LSMASHSource"}},
+		{"filter without plugin", FilterChain{NewFilter("Crop")}, []string{"LSMASHSource"}},
+		{"disabled filter", FilterChain{disabled}, []string{"LSMASHSource"}},
+		{"unknown filter", FilterChain{{Name: "Unknown", Enabled: true}}, []string{"LSMASHSource"}},
+		{"dependencies", FilterChain{NewFilter("Deinterlace")},
+			[]string{"LSMASHSource", "QTGMC", "MVTools2", "masktools2", "RgTools", "nnedi3"}},
+		{"chain", FilterChain{NewFilter("Denoise"), NewFilter("Crop"), NewFilter("Sharpen")},
+			[]string{"LSMASHSource", "FFT3DFilter", "CAS"}},
+		{"each plugin once", FilterChain{NewFilter("Deblock"), NewFilter("Deblock")},
+			[]string{"LSMASHSource", "Deblock"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequiredPlugins(&Job{Filters: tt.filters}); !slices.Equal(got, tt.want) {
+				t.Errorf("RequiredPlugins() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPluginKey(t *testing.T) {
+	tests := map[string]string{
+		"LSMASHSource.dll":   "lsmashsource",
+		"MVTools2_x64.dll":   "mvtools2",
+		"masktools2-x86.DLL": "masktools2",
+		"QTGMC.avsi":         "qtgmc",
+		"nnedi3":             "nnedi3",
+	}
+	for name, want := range tests {
+		if got := pluginKey(name); got != want {
+			t.Errorf("pluginKey(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCheckPlugins(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"LSMASHSource.dll", "QTGMC.avsi", "libmvtools2.dll", "RgTools_x64.dll", "readme.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// directories are not autoloaded
+	if err := os.Mkdir(filepath.Join(dir, "nnedi3.dll"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	j := &Job{Filters: FilterChain{NewFilter("Crop")}}
+	if err := CheckPlugins(j, dir); err != nil {
+		t.Errorf("CheckPlugins() = %v", err)
+	}
+	if err := CheckPlugins(&Job{Filters: FilterChain{NewFilter("Deinterlace")}}, ""); err != nil {
+		t.Errorf("CheckPlugins() without directory = %v", err)
+	}
+
+	j.Filters = FilterChain{NewFilter("Deinterlace")}
+	err := CheckPlugins(j, dir)
+	if err == nil || !strings.HasSuffix(err.Error(), ": masktools2, nnedi3") {
+		t.Errorf("CheckPlugins() = %v, want masktools2 and nnedi3 missing", err)
+	}
+	if err := CheckPlugins(j, filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing plugin directory accepted")
+	}
+
+	inv, err := ScanPlugins(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := inv.Path("MVTools2"); got != filepath.Join(dir, "libmvtools2.dll") {
+		t.Errorf("Path(MVTools2) = %q", got)
+	}
+	if got := inv.Filters(); !slices.Contains(got, "Crop") || slices.Contains(got, "Deinterlace") {
+		t.Errorf("Filters() = %q, want Crop without Deinterlace", got)
+	}
+}
@@ -0,0 +1,185 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RateControl selects how the encoder distributes the bits.
+type RateControl string
+
+const (
+	RateNone    RateControl = ""        // codec default, lossless and intra codecs
+	RateCRF     RateControl = "crf"     // constant quality
+	RateBitrate RateControl = "bitrate" // average bit rate, single pass
+	RateTwoPass RateControl = "2pass"   // average bit rate, two passes
+)
+
+// Preset describes an encoder configuration. Built-in presets are defined
+// in builtinPresets, user presets are YAML files like
+//
+//	name: MKV (x264 film)
+//	codec: libx264
+//	container: mkv
+//	rate_control: crf
+//	crf: 18
+//	profile: high
+//	pixel_format: yuv420p
+//	tune: film
+type Preset struct {
+	Name        string      `yaml:"name"`                   // name shown in the encoder dropdown
+	Codec       string      `yaml:"codec"`                  // ffmpeg encoder like "libx264"
+	Container   Container   `yaml:"container"`              // file format of the output
+	RateControl RateControl `yaml:"rate_control,omitempty"` // rate control mode
+	CRF         int         `yaml:"crf,omitempty"`          // quality for RateCRF
+	Bitrate     int         `yaml:"bitrate,omitempty"`      // video bit rate in kbit/s for RateBitrate and RateTwoPass
+	Profile     string      `yaml:"profile,omitempty"`      // codec profile like "high10"
+	Level       string      `yaml:"level,omitempty"`        // codec level like "4.1"
+	PixelFormat string      `yaml:"pixel_format,omitempty"` // ffmpeg pixel format like "yuv420p10le"
+	Tune        string      `yaml:"tune,omitempty"`         // encoder tuning like "film"
+	FourCC      string      `yaml:"fourcc,omitempty"`       // VfW codec used by VirtualDub2, empty if there is none
+}
+
+// builtinPresets are always available, user presets with the same name
+// replace them.
+var builtinPresets = []Preset{
+	{Name: "MP4 (x264 8bit)", Codec: "libx264", Container: ContainerMP4, RateControl: RateCRF, CRF: 20,
+		Profile: "high", PixelFormat: "yuv420p", FourCC: "x264"},
+	{Name: "MP4 (x264 10bit)", Codec: "libx264", Container: ContainerMP4, RateControl: RateCRF, CRF: 20,
+		Profile: "high10", PixelFormat: "yuv420p10le", FourCC: "x264"},
+	{Name: "MP4 (x265 HEVC)", Codec: "libx265", Container: ContainerMP4, RateControl: RateCRF, CRF: 22,
+		Profile: "main10", PixelFormat: "yuv420p10le", FourCC: "x265"},
+	{Name: "Huffyuv (lossless)", Codec: "huffyuv", Container: ContainerAVI, PixelFormat: "yuv422p", FourCC: "hfyu"},
+	{Name: "MKV (FFV1 lossless)", Codec: "ffv1", Container: ContainerMKV, PixelFormat: "yuv422p10le"},
+	{Name: "MOV (ProRes 422 HQ)", Codec: "prores_ks", Container: ContainerMOV, Profile: "hq", PixelFormat: "yuv422p10le"},
+	{Name: "MKV (AV1 SVT)", Codec: "libsvtav1", Container: ContainerMKV, RateControl: RateCRF, CRF: 30,
+		PixelFormat: "yuv420p10le"},
+}
+
+// Validate checks the preset for missing and out of range values.
+func (p Preset) Validate() error {
+	var errs []error
+	if strings.TrimSpace(p.Name) == "" {
+		errs = append(errs, errors.New("name missing"))
+	}
+	if p.Codec == "" {
+		errs = append(errs, errors.New("codec missing"))
+	}
+	if _, ok := containerAudio[p.Container]; !ok {
+		errs = append(errs, fmt.Errorf("unknown container %q", p.Container))
+	}
+	switch p.RateControl {
+	case RateNone:
+	case RateCRF:
+		if p.CRF < 0 || p.CRF > 63 {
+			errs = append(errs, fmt.Errorf("crf %d out of range 0..63", p.CRF))
+		}
+	case RateBitrate, RateTwoPass:
+		if p.Bitrate <= 0 {
+			errs = append(errs, fmt.Errorf("bitrate missing for rate control %s", p.RateControl))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown rate control %q", p.RateControl))
+	}
+	if p.FourCC != "" && len(p.FourCC) != 4 {
+		errs = append(errs, fmt.Errorf("fourcc %q must have 4 characters", p.FourCC))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("preset %q: %w", p.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// Presets is the registry of the encoder presets in the order shown to the
+// user, built-in presets first.
+type Presets struct {
+	list []Preset
+}
+
+// NewPresets returns a registry holding the built-in presets.
+func NewPresets() *Presets {
+	return &Presets{list: slices.Clone(builtinPresets)}
+}
+
+// LoadPresets returns the built-in presets and the presets of all YAML
+// files in the directory. Invalid files are skipped and reported in the
+// error, a missing directory is no error.
+func LoadPresets(dir string) (*Presets, error) {
+	p := NewPresets()
+	if dir == "" {
+		return p, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	} else if err != nil {
+		return p, err
+	}
+
+	var errs []error
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		preset, err := readPreset(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.Add(preset)
+	}
+	return p, errors.Join(errs...)
+}
+
+// readPreset reads and validates a preset file.
+func readPreset(path string) (Preset, error) {
+	var preset Preset
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return preset, err
+	}
+	if err := yaml.UnmarshalStrict(data, &preset); err != nil {
+		return preset, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := preset.Validate(); err != nil {
+		return preset, fmt.Errorf("%s: %w", path, err)
+	}
+	return preset, nil
+}
+
+// Add adds the preset or replaces the preset with the same name.
+func (p *Presets) Add(preset Preset) {
+	for i := range p.list {
+		if p.list[i].Name == preset.Name {
+			p.list[i] = preset
+			return
+		}
+	}
+	p.list = append(p.list, preset)
+}
+
+// Get returns the preset with the name.
+func (p *Presets) Get(name string) (Preset, bool) {
+	for _, preset := range p.list {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// Names returns the names of all presets.
+func (p *Presets) Names() []string {
+	names := make([]string, len(p.list))
+	for i, preset := range p.list {
+		names[i] = preset.Name
+	}
+	return names
+}
@@ -0,0 +1,110 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadPresets(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// replaces the built-in preset with the same name
+		"x264.yaml": "name: MP4 (x264 8bit)\ncodec: libx264\ncontainer: mp4\nrate_control: crf\ncrf: 16\n",
+		"film.yml":  "name: MKV (x264 film)\ncodec: libx264\ncontainer: mkv\nrate_control: crf\ncrf: 18\ntune: film\n",
+		"typo.yaml": "name: Typo\ncodec: libx264\ncontainer: mkv\ncfr: 18\n",
+		"bad.yaml":  "name: Bad\ncodec: libx264\ncontainer: mkv\nrate_control: 2pass\n",
+		"notes.txt": "name: Ignored\ncodec: libx264\ncontainer: mkv\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "filters.yaml"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadPresets(dir)
+	if err == nil {
+		t.Error("invalid preset files not reported")
+	} else {
+		for _, file := range []string{"typo.yaml", "bad.yaml"} {
+			if !strings.Contains(err.Error(), file) {
+				t.Errorf("error %q doesn't name %s", err, file)
+			}
+		}
+	}
+
+	names := p.Names()
+	if len(names) != len(builtinPresets)+1 {
+		t.Errorf("Names() = %q, want the built-in presets and one user preset", names)
+	}
+	if names[0] != builtinPresets[0].Name || names[len(names)-1] != "MKV (x264 film)" {
+		t.Errorf("Names() = %q, want the built-in presets first", names)
+	}
+	for _, name := range []string{"Typo", "Bad", "Ignored"} {
+		if slices.Contains(names, name) {
+			t.Errorf("preset %q loaded", name)
+		}
+	}
+
+	// the whole preset is replaced, fields of the built-in preset are not merged
+	x264, _ := p.Get("MP4 (x264 8bit)")
+	want := Preset{Name: "MP4 (x264 8bit)", Codec: "libx264", Container: ContainerMP4, RateControl: RateCRF, CRF: 16}
+	if x264 != want {
+		t.Errorf("Get() = %+v, want %+v", x264, want)
+	}
+	if builtin := NewPresets(); slices.Equal(builtin.Names(), names) {
+		t.Error("user presets changed the built-in presets")
+	}
+	if b, _ := NewPresets().Get("MP4 (x264 8bit)"); b.CRF != 20 {
+		t.Errorf("built-in preset changed: %+v", b)
+	}
+}
+
+func TestLoadPresetsWithoutDir(t *testing.T) {
+	for _, dir := range []string{"", filepath.Join(t.TempDir(), "missing")} {
+		p, err := LoadPresets(dir)
+		if err != nil || len(p.Names()) != len(builtinPresets) {
+			t.Errorf("LoadPresets(%q) = %q, %v, want the built-in presets", dir, p.Names(), err)
+		}
+	}
+}
+
+func TestPresetValidate(t *testing.T) {
+	valid := Preset{Name: "x264", Codec: "libx264", Container: ContainerMKV}
+	tests := []struct {
+		name    string
+		change  func(p *Preset)
+		wantErr bool
+	}{
+		{"valid", func(p *Preset) {}, false},
+		{"blank name", func(p *Preset) { p.Name = " " }, true},
+		{"codec missing", func(p *Preset) { p.Codec = "" }, true},
+		{"unknown container", func(p *Preset) { p.Container = "wmv" }, true},
+		{"crf", func(p *Preset) { p.RateControl, p.CRF = RateCRF, 18 }, false},
+		{"crf out of range", func(p *Preset) { p.RateControl, p.CRF = RateCRF, 64 }, true},
+		{"bitrate", func(p *Preset) { p.RateControl, p.Bitrate = RateBitrate, 4000 }, false},
+		{"bitrate missing", func(p *Preset) { p.RateControl = RateTwoPass }, true},
+		{"unknown rate control", func(p *Preset) { p.RateControl = "cq" }, true},
+		{"fourcc", func(p *Preset) { p.FourCC = "x264" }, false},
+		{"short fourcc", func(p *Preset) { p.FourCC = "x26" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := valid
+			tt.change(&p)
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+	for _, p := range builtinPresets {
+		if err := p.Validate(); err != nil {
+			t.Errorf("built-in preset: %v", err)
+		}
+	}
+}
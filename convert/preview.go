@@ -0,0 +1,105 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PreviewJob returns a copy of the job for the preview of the whole source
+// with the scripts and frames in the directory.
+func PreviewJob(j *Job, dir string) *Job {
+	p := *j
+	p.TrimStart, p.TrimEnd = 0, 0
+	p.WorkDir = dir
+	p.Workspace = Workspace{}
+	return &p
+}
+
+// PreviewPaths returns the PNG images of the source frame and of the
+// filtered frame rendered by RenderPreview.
+func (j *Job) PreviewPaths() (before, after string) {
+	return j.WorkPath(".before.png"), j.WorkPath(".after.png")
+}
+
+// SourceFrameArgs returns the ffmpeg arguments writing the source frame at
+// the time as PNG image.
+func SourceFrameArgs(j *Job, at time.Duration, out string) []string {
+	args := []string{"-y", "-ss", seconds(at), "-i", j.Source.FullPath}
+	if v := j.Source.FirstVideo(); v != nil {
+		args = append(args, "-map", fmt.Sprintf("0:%d", v.Index))
+	}
+	return append(args, "-frames:v", "1", out)
+}
+
+// FilteredFrameArgs returns the ffmpeg arguments writing the filtered frame
+// at the time as PNG image, filtered like by the ffmpeg backend.
+func FilteredFrameArgs(j *Job, at time.Duration, out string) []string {
+	args := []string{"-y", "-ss", seconds(at), "-i", j.Source.FullPath}
+	args = append(args, videoMapArgs(j, nil)...)
+	return append(args, "-frames:v", "1", out)
+}
+
+// RenderPreview writes the source frame at the time and the same frame
+// filtered by the script of the backend as PNG images, see PreviewPaths.
+// VapourSynth scripts are rendered by vspipe, AviSynth+ scripts of the
+// VirtualDub2 backend are read by ffmpeg, which needs AviSynth+ support.
+// The ffmpeg backend uses the ffmpeg filters. The job should be a
+// PreviewJob.
+func RenderPreview(ctx context.Context, t Tools, backend string, j *Job, at time.Duration) error {
+	if err := prepareDirs(j); err != nil {
+		return err
+	}
+	before, after := j.PreviewPaths()
+	if err := runFrame(ctx, t.FFmpeg, SourceFrameArgs(j, at, before), nil); err != nil {
+		return err
+	}
+
+	switch {
+	case j.Frameserver == FrameserverVapourSynth:
+		script := j.WorkPath(".vpy")
+		if err := j.writeWorkFile(script, VapourSynthScript(j, t.VapourSynthPlugins)); err != nil {
+			return err
+		}
+		frame := "0"
+		if rate, ok := j.ScriptRate(); ok {
+			frame = strconv.FormatInt(rate.Frames(at), 10)
+		}
+		vspipe := exec.CommandContext(ctx, t.VSPipe, "-c", "y4m", "-s", frame, "-e", frame, script, "-")
+		return runFrame(ctx, t.FFmpeg, []string{"-y", "-f", "yuv4mpegpipe", "-i", "-", "-frames:v", "1", after}, vspipe)
+	case backend == BackendVirtualDub:
+		script := j.WorkPath(".avs")
+		if err := j.writeWorkFile(script, AviSynthScript(j, t.AviSynthPlugins)); err != nil {
+			return err
+		}
+		args := []string{"-y", "-ss", seconds(at), "-f", "avisynth", "-i", script, "-frames:v", "1", after}
+		return runFrame(ctx, t.FFmpeg, args, nil)
+	}
+	return runFrame(ctx, t.FFmpeg, FilteredFrameArgs(j, at, after), nil)
+}
+
+// runFrame runs ffmpeg writing a single frame, reading stdin from the
+// source command if there is one.
+func runFrame(ctx context.Context, ffmpeg string, args []string, source *exec.Cmd) error {
+	cmd := exec.CommandContext(ctx, ffmpeg, append([]string{"-hide_banner", "-v", "error"}, args...)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if source != nil {
+		pipe, err := source.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		cmd.Stdin = pipe
+		if err := source.Start(); err != nil {
+			return fmt.Errorf("preview: %w", err)
+		}
+		defer source.Wait()
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("preview: %w: %s", err, lastLines(stderr.String(), 3))
+	}
+	return nil
+}
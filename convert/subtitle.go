@@ -0,0 +1,216 @@
+package convert
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// SubtitleMode defines what happens with the subtitles of the source.
+type SubtitleMode string
+
+const (
+	SubtitlesNone    SubtitleMode = ""        // subtitles are dropped
+	SubtitlesSidecar SubtitleMode = "sidecar" // subtitles are extracted next to the output
+	SubtitlesMux     SubtitleMode = "mux"     // subtitles are muxed into the output, sidecar if the container can't carry them
+)
+
+// SubtitleSettings configures the subtitle handling of a project.
+type SubtitleSettings struct {
+	Mode     SubtitleMode // drop, extract or mux the subtitles
+	Captions bool         // include EIA-608 closed captions, converted to text
+}
+
+// SubtitleFormat is the file format of an extracted subtitle.
+type SubtitleFormat string
+
+const (
+	SubtitleNone SubtitleFormat = ""    // no sidecar format
+	SubtitleSRT  SubtitleFormat = "srt" // text subtitles and closed captions
+	SubtitleSUP  SubtitleFormat = "sup" // Blu-ray PGS subtitles
+)
+
+// SubtitleFormatOf returns the sidecar format of the subtitle stream. Text
+// subtitles and captions are written as SRT, PGS as SUP. ffmpeg can't write
+// VobSub (.idx/.sub), DVD and other bitmap subtitles have no sidecar format
+// and can only be muxed, see checkSubtitles.
+func SubtitleFormatOf(s util.SubtitleStream) SubtitleFormat {
+	switch s.Codec {
+	case "hdmv_pgs_subtitle":
+		return SubtitleSUP
+	case "dvd_subtitle", "dvb_subtitle", "xsub":
+		return SubtitleNone
+	}
+	return SubtitleSRT
+}
+
+// checkSubtitles returns an error if a subtitle of the job has to be
+// extracted but has no sidecar format.
+func checkSubtitles(j *Job) error {
+	for _, s := range j.SidecarSubtitles() {
+		if SubtitleFormatOf(s) == SubtitleNone {
+			return fmt.Errorf("%s: %s subtitle #%d can't be written as sidecar file, "+
+				"mux it into MKV or drop the subtitles", j.Source.Name, s.Codec, s.Index)
+		}
+	}
+	return nil
+}
+
+// ffmpegSubtitleCodec returns the ffmpeg encoder writing the subtitle into
+// the container, false if the container can't carry the subtitle.
+func ffmpegSubtitleCodec(s util.SubtitleStream, c Container) (string, bool) {
+	switch c {
+	case ContainerMKV:
+		if s.Captions {
+			return "srt", true
+		}
+		return "copy", true
+	case ContainerMP4, ContainerMOV:
+		if !s.Bitmap() {
+			return "mov_text", true
+		}
+		// MP4 supports DVD subtitles, but no other bitmap subtitles
+		return "copy", c == ContainerMP4 && s.Codec == "dvd_subtitle"
+	}
+	return "", false
+}
+
+// SelectedSubtitles returns the subtitle streams of the source to keep.
+func (j *Job) SelectedSubtitles() []util.SubtitleStream {
+	if j.Subtitles.Mode == SubtitlesNone {
+		return nil
+	}
+	var subs []util.SubtitleStream
+	for _, s := range j.Source.Subtitles {
+		if s.Captions && !j.Subtitles.Captions {
+			continue
+		}
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// MuxedSubtitles returns the subtitles muxed into the output container.
+func (j *Job) MuxedSubtitles() []util.SubtitleStream {
+	if j.Subtitles.Mode != SubtitlesMux {
+		return nil
+	}
+	var subs []util.SubtitleStream
+	for _, s := range j.SelectedSubtitles() {
+		if _, ok := ffmpegSubtitleCodec(s, j.Container); ok {
+			subs = append(subs, s)
+		}
+	}
+	return subs
+}
+
+// SidecarSubtitles returns the subtitles extracted into sidecar files,
+// either all of them or those the container can't carry.
+func (j *Job) SidecarSubtitles() []util.SubtitleStream {
+	var subs []util.SubtitleStream
+	for _, s := range j.SelectedSubtitles() {
+		if _, ok := ffmpegSubtitleCodec(s, j.Container); ok && j.Subtitles.Mode == SubtitlesMux {
+			continue
+		}
+		subs = append(subs, s)
+	}
+	return subs
+}
+
+// SidecarPath returns the path of the extracted subtitle next to the output,
+// like "movie.2.eng.srt" or "movie.1.cc.srt" for closed captions.
+func (j *Job) SidecarPath(s util.SubtitleStream) string {
+	parts := []string{j.BaseName(), fmt.Sprint(s.Index)}
+	if s.Language != "" {
+		parts = append(parts, s.Language)
+	}
+	if s.Captions {
+		parts = append(parts, "cc")
+	}
+	if s.Forced {
+		parts = append(parts, "forced")
+	}
+	parts = append(parts, string(SubtitleFormatOf(s)))
+	return filepath.Join(j.OutputDir, strings.Join(parts, "."))
+}
+
+// SidecarArgs returns the ffmpeg arguments extracting the subtitle into its
+// sidecar file.
+func SidecarArgs(j *Job, s util.SubtitleStream) []string {
+	args := append([]string{"-y"}, subtitleInput(j, s)...)
+	if s.Captions {
+		args = append(args, "-map", "0:s")
+	} else {
+		args = append(args, "-map", fmt.Sprintf("0:%d", s.Index))
+	}
+
+	switch SubtitleFormatOf(s) {
+	case SubtitleSUP:
+		args = append(args, "-c:s", "copy", "-f", "sup")
+	default:
+		args = append(args, "-c:s", "srt")
+	}
+	return append(args, j.SidecarPath(s))
+}
+
+// subtitleInput returns the ffmpeg input of the subtitle, trimmed like the
+// video. Closed captions are read with the lavfi movie source, which
+// exposes them as an own stream.
+func subtitleInput(j *Job, s util.SubtitleStream) []string {
+	if s.Captions {
+		return append(j.seekArgs(), "-f", "lavfi", "-i", fmt.Sprintf("movie=%s:si=%d[out0+subcc]", lavfiPath(j.Source.FullPath), s.Index))
+	}
+	return append(j.seekArgs(), "-i", j.Source.FullPath)
+}
+
+// lavfiPath escapes the path as option value inside a filter graph, first
+// for the option and then for the graph.
+func lavfiPath(path string) string {
+	escape := func(s, special string) string {
+		var b strings.Builder
+		for _, r := range s {
+			if strings.ContainsRune(special, r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	}
+	return escape(escape(filepath.ToSlash(path), `\':`), `\'[],;`)
+}
+
+// subtitleMuxArgs returns the inputs, maps and codecs muxing the subtitles
+// into the output. The source is added as input once for all subtitle
+// streams, each closed caption stream needs an own lavfi input. Inputs are
+// numbered from first.
+func subtitleMuxArgs(j *Job, first int) (inputs, maps []string) {
+	subs := j.MuxedSubtitles()
+	source := -1
+	for i, s := range subs {
+		switch {
+		case s.Captions:
+			inputs = append(inputs, subtitleInput(j, s)...)
+			maps = append(maps, "-map", fmt.Sprintf("%d:s", first))
+			first++
+		default:
+			if source < 0 {
+				inputs = append(inputs, subtitleInput(j, s)...)
+				source = first
+				first++
+			}
+			maps = append(maps, "-map", fmt.Sprintf("%d:%d", source, s.Index))
+		}
+
+		codec, _ := ffmpegSubtitleCodec(s, j.Container)
+		maps = append(maps, fmt.Sprintf("-c:s:%d", i), codec)
+		if s.Language != "" {
+			maps = append(maps, fmt.Sprintf("-metadata:s:s:%d", i), "language="+s.Language)
+		}
+		if s.Forced {
+			maps = append(maps, fmt.Sprintf("-disposition:s:%d", i), "forced")
+		}
+	}
+	return inputs, maps
+}
@@ -0,0 +1,41 @@
+package convert
+
+import (
+	"testing"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+func TestCheckSubtitles(t *testing.T) {
+	dvd := util.SubtitleStream{Index: 3, Codec: "dvd_subtitle"}
+	srt := util.SubtitleStream{Index: 2, Codec: "subrip"}
+	pgs := util.SubtitleStream{Index: 4, Codec: "hdmv_pgs_subtitle"}
+	tests := []struct {
+		name      string
+		mode      SubtitleMode
+		container Container
+		subs      []util.SubtitleStream
+		wantErr   bool
+	}{
+		{"dropped", SubtitlesNone, ContainerAVI, []util.SubtitleStream{dvd}, false},
+		{"text as sidecar", SubtitlesSidecar, ContainerMP4, []util.SubtitleStream{srt}, false},
+		{"pgs as sidecar", SubtitlesSidecar, ContainerMP4, []util.SubtitleStream{pgs}, false},
+		{"dvd as sidecar", SubtitlesSidecar, ContainerMKV, []util.SubtitleStream{srt, dvd}, true},
+		{"dvd muxed into mkv", SubtitlesMux, ContainerMKV, []util.SubtitleStream{dvd}, false},
+		{"dvd muxed into mp4", SubtitlesMux, ContainerMP4, []util.SubtitleStream{dvd}, false},
+		{"dvd muxed into avi", SubtitlesMux, ContainerAVI, []util.SubtitleStream{dvd}, true},
+		{"dvb muxed into mp4", SubtitlesMux, ContainerMP4, []util.SubtitleStream{{Index: 5, Codec: "dvb_subtitle"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{
+				Source:    &util.MediaInfo{Name: "movie.vob", Subtitles: tt.subs},
+				Container: tt.container,
+				Subtitles: SubtitleSettings{Mode: tt.mode},
+			}
+			if err := checkSubtitles(j); (err != nil) != tt.wantErr {
+				t.Errorf("checkSubtitles() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package convert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// twoPassCodecs are the ffmpeg encoders supporting two-pass encoding.
+var twoPassCodecs = []string{"libx264", "libx265", "libvpx-vp9", "mpeg2video", "mpeg4"}
+
+// muxOverhead is the share of the file size used by the container.
+const muxOverhead = 0.01
+
+// minVideoBitrate is the lowest video bit rate in kbit/s accepted for a
+// target size.
+const minVideoBitrate = 100
+
+// TwoPass returns true if the preset encodes in two passes.
+func (p Preset) TwoPass() bool {
+	return p.RateControl == RateTwoPass
+}
+
+// TargetBitrate returns the video bit rate in kbit/s filling the target
+// size in bytes with the output of the job, after the audio tracks and the
+// container overhead.
+func TargetBitrate(j *Job, size int64) (int, error) {
+	duration := j.OutputDuration().Seconds()
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration of %s unknown", j.Source.Name)
+	}
+
+	tracks := len(j.SelectedAudio())
+	if tracks > 0 && j.Audio.Codec.Lossless() {
+		return 0, fmt.Errorf("target size needs a lossy audio codec, not %s", j.Audio.Codec)
+	}
+	audio := tracks * j.Audio.Bitrate
+
+	total := float64(size) * 8 / 1000 * (1 - muxOverhead) / duration
+	video := int(total) - audio
+	if video < minVideoBitrate {
+		return 0, fmt.Errorf("target size %s too small for %s, video would get %d kbit/s",
+			formatMB(size), j.Source.Name, video)
+	}
+	return video, nil
+}
+
+// ApplyTargetSize switches the preset of the job to two-pass encoding with
+// the bit rate reaching the target size. Jobs without target size are not
+// changed.
+func (j *Job) ApplyTargetSize() error {
+	if j.TargetSize <= 0 {
+		return nil
+	}
+	if !slices.Contains(twoPassCodecs, j.Preset.Codec) {
+		return fmt.Errorf("preset %q can't encode to a target size", j.Preset.Name)
+	}
+	bitrate, err := TargetBitrate(j, j.TargetSize)
+	if err != nil {
+		return err
+	}
+	j.Preset.RateControl = RateTwoPass
+	j.Preset.Bitrate = bitrate
+	return nil
+}
+
+// PassLogPath returns the prefix of the pass log files in the work directory.
+func (j *Job) PassLogPath() string {
+	return j.WorkPath(".pass")
+}
+
+// passArgs returns the ffmpeg options of the pass. x265 takes its pass
+// options as encoder parameters.
+func passArgs(j *Job, pass int) []string {
+	if j.Preset.Codec == "libx265" {
+		stats := strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(filepath.ToSlash(j.PassLogPath() + ".log"))
+		return []string{"-x265-params", fmt.Sprintf("pass=%d:stats=%s", pass, stats)}
+	}
+	return []string{"-pass", strconv.Itoa(pass), "-passlogfile", j.PassLogPath()}
+}
+
+// trackPassLogs adds the pass log files to the workspace. ffmpeg appends
+// the stream number like "-0.log", x265 writes ".log" and ".log.cutree".
+func trackPassLogs(j *Job) {
+	prefix := globEscape(j.PassLogPath())
+	j.Workspace.TrackPattern(prefix + "-*.log*")
+	j.Workspace.TrackPattern(prefix + ".log*")
+}
+
+// globEscape escapes the glob meta characters of the path as character
+// classes, the backslash is the path separator on Windows.
+func globEscape(path string) string {
+	return strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]").Replace(path)
+}
+
+// SizeReport returns the size of the output, compared to the target size
+// if there is one.
+func SizeReport(j *Job) (string, error) {
+	fi, err := os.Stat(j.OutputPath())
+	if err != nil {
+		return "", err
+	}
+	if j.TargetSize <= 0 {
+		return formatMB(fi.Size()), nil
+	}
+	diff := 100 * (float64(fi.Size()) - float64(j.TargetSize)) / float64(j.TargetSize)
+	return fmt.Sprintf("%s of %s target (%+.1f%%)", formatMB(fi.Size()), formatMB(j.TargetSize), diff), nil
+}
+
+// formatMB returns the size in MB.
+func formatMB(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/1e6)
+}
@@ -0,0 +1,112 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// targetJob returns a job of a source of 100 seconds with two audio tracks,
+// encoded with the first built-in preset.
+func targetJob() *Job {
+	return &Job{
+		Source: &util.MediaInfo{
+			Name:     "clip.avi",
+			Duration: 100 * time.Second,
+			Audio:    []util.AudioStream{{Index: 1}, {Index: 2}},
+		},
+		Preset:    builtinPresets[0],
+		Container: ContainerMP4,
+		Audio:     NewAudioSettings(),
+	}
+}
+
+func TestTargetBitrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		change  func(j *Job)
+		want    int
+		wantErr string
+	}{
+		// 100 MB in 100 s are 8000 kbit/s, 7920 kbit/s without the overhead
+		{"first audio track", 100e6, nil, 7920 - 192, ""},
+		{"two audio tracks", 100e6, func(j *Job) { j.AudioTracks = []int{1, 2} }, 7920 - 2*192, ""},
+		{"no audio", 100e6, func(j *Job) { j.Source.Audio = nil }, 7920, ""},
+		{"audio bit rate", 100e6, func(j *Job) { j.Audio.Bitrate = 320 }, 7920 - 320, ""},
+		{"trimmed", 100e6, func(j *Job) { j.TrimStart, j.TrimEnd = 25*time.Second, 75*time.Second }, 2*7920 - 192, ""},
+		{"too small", 1e6, nil, 0, "too small"},
+		{"lossless audio", 100e6, func(j *Job) { j.Audio.Codec = AudioFLAC }, 0, "lossy audio codec"},
+		{"lossless audio without tracks", 100e6, func(j *Job) { j.Audio.Codec = AudioFLAC; j.Source.Audio = nil }, 7920, ""},
+		{"unknown duration", 100e6, func(j *Job) { j.Source.Duration = 0 }, 0, "duration"},
+		{"trimmed behind the end", 100e6, func(j *Job) { j.TrimStart = 200 * time.Second }, 0, "duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := targetJob()
+			if tt.change != nil {
+				tt.change(j)
+			}
+			got, err := TargetBitrate(j, tt.size)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("TargetBitrate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the overhead may round down by one
+			if got != tt.want && got != tt.want-1 {
+				t.Errorf("TargetBitrate() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyTargetSize(t *testing.T) {
+	j := targetJob()
+	if err := j.ApplyTargetSize(); err != nil || j.Preset != builtinPresets[0] {
+		t.Errorf("without target size: preset %+v, error %v", j.Preset, err)
+	}
+
+	j.TargetSize = 100e6
+	if err := j.ApplyTargetSize(); err != nil {
+		t.Fatal(err)
+	}
+	if !j.Preset.TwoPass() || j.Preset.Bitrate < 7700 || j.Preset.Bitrate > 7728 {
+		t.Errorf("preset %+v, want two passes with about 7728 kbit/s", j.Preset)
+	}
+
+	j = targetJob()
+	j.Preset = Preset{Name: "ProRes", Codec: "prores_ks"}
+	j.TargetSize = 100e6
+	if err := j.ApplyTargetSize(); err == nil {
+		t.Error("ProRes accepted a target size")
+	}
+}
+
+// The batch applies the target size before the check, VirtualDub2 can't
+// run the two passes without two-stage mode.
+func TestTargetSizeCheck(t *testing.T) {
+	b := &VirtualDubBackend{Tools{FFmpeg: "ffmpeg", VirtualDub: "VirtualDub64.exe"}}
+	j := targetJob()
+	j.Frameserver = FrameserverVapourSynth
+	if err := b.Check(j); err != nil {
+		t.Fatalf("without target size: %v", err)
+	}
+	j.TargetSize = 100e6
+	if err := j.ApplyTargetSize(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Check(j); err == nil || !strings.Contains(err.Error(), "two-pass") {
+		t.Errorf("Check() = %v, want two-pass error", err)
+	}
+	j.Intermediate = LosslessFFV1
+	if err := b.Check(j); err != nil {
+		t.Errorf("two-stage mode: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frameserver is the script language the source is loaded and filtered with.
+type Frameserver string
+
+const (
+	FrameserverAviSynth    Frameserver = ""            // AviSynth+, video and audio
+	FrameserverVapourSynth Frameserver = "vapoursynth" // VapourSynth, video only
+)
+
+// pyString returns the text as Python string literal.
+func pyString(s string) string {
+	return strconv.Quote(s)
+}
+
+// VapourSynthScript returns the VapourSynth script of the job. VapourSynth
+// only processes the video, the audio is taken from the source by ffmpeg
+// with the filters of the ffmpeg backend. Plugins are loaded from the
+// plugin directory, the default plugin paths are used if it is empty. The
+// paths are translated with the path map of the job for VirtualDub2.
+func VapourSynthScript(j *Job, pluginDir string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# VapourSynth script for %s\n\n", j.Source.Name)
+	b.WriteString("import vapoursynth as vs\n")
+	imports, filters := j.Filters.VapourSynth()
+	for _, imp := range imports {
+		b.WriteString(imp + "\n")
+	}
+	b.WriteString("core = vs.core\n")
+	if pluginDir != "" {
+		fmt.Fprintf(&b, "core.std.LoadAllPlugins(%s)\n", pyString(j.scriptPath(pluginDir)))
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "clip = core.lsmas.LWLibavSource(%s, cachefile=%s)\n",
+		pyString(j.scriptPath(j.Source.FullPath)), pyString(j.scriptPath(j.IndexPath())))
+	for _, line := range filters {
+		b.WriteString(line + "\n")
+	}
+	if w, h := j.OutputSize(); w > 0 {
+		fmt.Fprintf(&b, "clip = core.resize.Spline36(clip, %d, %d)\n", w, h)
+	}
+
+	// Slices cut the clip at frame boundaries, the end is exclusive
+	if rate, ok := j.ScriptRate(); ok && j.Trimmed() {
+		end := ""
+		if j.TrimEnd > 0 {
+			end = strconv.FormatInt(rate.Frames(j.TrimEnd), 10)
+		}
+		fmt.Fprintf(&b, "clip = clip[%d:%s]\n", rate.Frames(j.TrimStart), end)
+	}
+	b.WriteString("clip.set_output()\n")
+	return b.String()
+}
+
+// runVSPipe renders the VapourSynth script with vspipe as YUV4MPEG stream
+// into ffmpeg, which reads it as input "-". The error output of ffmpeg is
+// returned like by runFFmpegInput.
+func runVSPipe(ctx context.Context, t Tools, script string, args []string, stage string, total time.Duration, report func(Progress)) (string, error) {
+	vspipe := exec.CommandContext(ctx, t.VSPipe, "-c", "y4m", script, "-")
+	var stderr strings.Builder
+	vspipe.Stderr = &stderr
+	stdout, err := vspipe.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := vspipe.Start(); err != nil {
+		return "", fmt.Errorf("%s: %w", stage, err)
+	}
+
+	log, ffmpegErr := runFFmpegInput(ctx, t.FFmpeg, args, stdout, stage, total, report)
+	// ffmpeg stops reading on errors, close the pipe to end vspipe
+	stdout.Close()
+	if err := vspipe.Wait(); err != nil && ffmpegErr == nil {
+		return log, fmt.Errorf("%s: vspipe: %w: %s", stage, err, lastLines(stderr.String(), 3))
+	}
+	return log, ffmpegErr
+}
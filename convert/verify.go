@@ -0,0 +1,105 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// VerifyMode defines how the output is checked after the conversion.
+type VerifyMode string
+
+const (
+	VerifyNone   VerifyMode = ""       // no check
+	VerifyProbe  VerifyMode = "probe"  // compare streams, duration and frames with the source
+	VerifyDecode VerifyMode = "decode" // probe and decode the whole output
+)
+
+// durationTolerance is the accepted difference of the output duration.
+// Encoders and muxers round the last frame and the audio frames.
+const durationTolerance = 500 * time.Millisecond
+
+// CheckOutput compares the probed output with the job. The duration and
+// the frame count have to match the trimmed source, a doubled frame rate
+// like after bob deinterlacing is accepted. All video, audio and muxed
+// subtitle streams have to be present.
+func CheckOutput(j *Job, out *util.MediaInfo) error {
+	var problems []string
+	expected := j.OutputDuration()
+	if diff := out.Length() - expected; diff > durationTolerance || diff < -durationTolerance {
+		problems = append(problems, fmt.Sprintf("output is %s long, expected %s",
+			mediatime.FormatDuration(out.Length()), mediatime.FormatDuration(expected)))
+	}
+
+	src, v := j.Source.FirstVideo(), out.FirstVideo()
+	switch {
+	case v == nil:
+		problems = append(problems, "output has no video stream")
+	case src != nil && !src.FrameRate.IsZero():
+		frames := src.FrameRate.Frames(expected)
+		switch {
+		case v.FrameRate.Equal(src.FrameRate.Rational):
+		case v.FrameRate.Equal(src.FrameRate.Double().Rational):
+			frames *= 2
+		default:
+			problems = append(problems, fmt.Sprintf("output has %s fps, source %s fps", v.FrameRate, src.FrameRate))
+		}
+		tolerance := v.FrameRate.Frames(durationTolerance)
+		if diff := v.Frames - frames; diff > tolerance || diff < -tolerance {
+			problems = append(problems, fmt.Sprintf("output has %d frames, expected %d", v.Frames, frames))
+		}
+	}
+
+	if n, want := len(out.Audio), len(j.SelectedAudio()); n != want {
+		problems = append(problems, fmt.Sprintf("output has %d audio streams, expected %d", n, want))
+	}
+	if n, want := len(out.Subtitles), len(j.MuxedSubtitles()); n < want {
+		problems = append(problems, fmt.Sprintf("output has %d subtitle streams, expected %d", n, want))
+	}
+	for _, s := range j.SidecarSubtitles() {
+		if _, err := os.Stat(j.SidecarPath(s)); err != nil {
+			problems = append(problems, fmt.Sprintf("subtitle file %s missing", j.SidecarPath(s)))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// DecodeArgs returns the ffmpeg arguments decoding the video and audio of
+// the output, stopping at the first decoding error.
+func DecodeArgs(j *Job) []string {
+	return []string{"-v", "error", "-xerror", "-err_detect", "explode",
+		"-i", j.OutputPath(), "-map", "0:v", "-map", "0:a?", "-f", "null", "-"}
+}
+
+// verify checks the output of the job with the verification mode of the job.
+func verify(ctx context.Context, ffmpeg string, j *Job, report func(Progress)) error {
+	if j.Verify == VerifyNone {
+		return nil
+	}
+	if report != nil {
+		report(Progress{Stage: "verify", Percent: -1})
+	}
+	probe, err := util.FFprobe(j.OutputPath())
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	if err := CheckOutput(j, util.NewMediaInfo(j.OutputPath(), probe)); err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+
+	if j.Verify == VerifyDecode {
+		if err := runFFmpeg(ctx, ffmpeg, DecodeArgs(j), "verify", j.OutputDuration(), report); err != nil {
+			return fmt.Errorf("output is corrupt: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+// probed returns the media info of a file with one video stream and the
+// number of audio streams.
+func probed(d time.Duration, rate mediatime.FrameRate, frames int64, audio int) *util.MediaInfo {
+	m := &util.MediaInfo{
+		Name:     "clip.mkv",
+		Duration: d,
+		Video:    []util.VideoStream{{FrameRate: rate, Duration: d, Frames: frames}},
+	}
+	for i := range audio {
+		m.Audio = append(m.Audio, util.AudioStream{Index: i + 1})
+	}
+	return m
+}
+
+func TestCheckOutput(t *testing.T) {
+	fps30 := mediatime.FrameRate{Rational: mediatime.NewRational(30, 1)}
+	tests := []struct {
+		name    string
+		source  *util.MediaInfo
+		change  func(j *Job)
+		out     *util.MediaInfo
+		wantErr string
+	}{
+		{"same", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, mediatime.FPS25, 2500, 1), ""},
+		{"within the tolerance", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100400*time.Millisecond, mediatime.FPS25, 2510, 1), ""},
+		{"too long", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(101*time.Second, mediatime.FPS25, 2500, 1), "long"},
+		{"too short", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(99*time.Second, mediatime.FPS25, 2475, 1), "long"},
+		{"frames missing", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, mediatime.FPS25, 2480, 1), "frames"},
+		{"trimmed", probed(100*time.Second, mediatime.FPS25, 2500, 1),
+			func(j *Job) { j.TrimStart, j.TrimEnd = 10*time.Second, 70*time.Second },
+			probed(60*time.Second, mediatime.FPS25, 1500, 1), ""},
+		{"trim ignored", probed(100*time.Second, mediatime.FPS25, 2500, 1),
+			func(j *Job) { j.TrimStart, j.TrimEnd = 10*time.Second, 70*time.Second },
+			probed(100*time.Second, mediatime.FPS25, 2500, 1), "expected 00:01:00.000"},
+		{"trimmed to the end", probed(100*time.Second, mediatime.FPS25, 2500, 1),
+			func(j *Job) { j.TrimStart = 40 * time.Second },
+			probed(60*time.Second, mediatime.FPS25, 1500, 1), ""},
+		{"doubled rate", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, mediatime.FPS50, 5000, 1), ""},
+		{"doubled ntsc rate", probed(100100*time.Millisecond, mediatime.FPS2997, 3000, 1), nil,
+			probed(100100*time.Millisecond, mediatime.FPS5994, 6000, 1), ""},
+		{"doubled rate with the frames of the source", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, mediatime.FPS50, 2500, 1), "frames"},
+		{"other rate", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, fps30, 3000, 1), "fps"},
+		{"unknown source rate", probed(100*time.Second, mediatime.FrameRate{}, 0, 1), nil,
+			probed(100*time.Second, mediatime.FPS25, 2500, 1), ""},
+		{"no video", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			&util.MediaInfo{Duration: 100 * time.Second, Audio: []util.AudioStream{{}}}, "no video"},
+		{"audio missing", probed(100*time.Second, mediatime.FPS25, 2500, 1), nil,
+			probed(100*time.Second, mediatime.FPS25, 2500, 0), "audio"},
+		{"selected tracks missing", probed(100*time.Second, mediatime.FPS25, 2500, 2),
+			func(j *Job) { j.AudioTracks = []int{1, 2} },
+			probed(100*time.Second, mediatime.FPS25, 2500, 1), "audio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Job{Source: tt.source, Container: ContainerMKV, OutputDir: t.TempDir()}
+			if tt.change != nil {
+				tt.change(j)
+			}
+			err := CheckOutput(j, tt.out)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("CheckOutput() = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("CheckOutput() = %v, want error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// fourCC converts a four character code into the number used in scripts.
+func fourCC(code string) uint32 {
+	return uint32(code[0]) | uint32(code[1])<<8 | uint32(code[2])<<16 | uint32(code[3])<<24
+}
+
+// vdString returns the text as string literal of a VirtualDub script.
+// Backslashes, quotes and control characters are escaped like in C, text
+// with non-ASCII characters is written as UTF-8 string U"...".
+func vdString(s string) string {
+	var b strings.Builder
+	ascii := true
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '"':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ':
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			ascii = ascii && r < utf8.RuneSelf
+			b.WriteRune(r)
+		}
+	}
+	if !ascii {
+		return `U"` + b.String() + `"`
+	}
+	return `"` + b.String() + `"`
+}
+
+// IntermediatePath returns the AVI file VirtualDub2 renders to. The AVI
+// holds the encoded video and the first audio track as PCM, the final
+// audio encoding and muxing into the container is done by ffmpeg. In
+// two-stage mode VirtualDub2 renders the lossless intermediate, which is
+// encoded by ffmpeg.
+func (j *Job) IntermediatePath() string {
+	if j.Intermediate != LosslessNone {
+		return j.LosslessPath()
+	}
+	return j.WorkPath(".vdub.avi")
+}
+
+// VirtualDubScript returns the VirtualDub2 script rendering the AviSynth+
+// or VapourSynth script of the job into the intermediate AVI file, with the
+// codec of the preset or the lossless codec in two-stage mode.
+func VirtualDubScript(j *Job, scriptPath string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// VirtualDub2 script for %s\n", j.Source.Name)
+	fmt.Fprintf(&b, "VirtualDub.Open(%s, \"\", 0);\n", vdString(j.scriptPath(scriptPath)))
+
+	// Audio is already processed by AviSynth, keep it as uncompressed PCM.
+	// VapourSynth scripts have no audio, it is muxed from the source.
+	if len(j.SelectedAudio()) > 0 && j.Frameserver != FrameserverVapourSynth {
+		b.WriteString("VirtualDub.audio.SetSource(1);\n")
+		b.WriteString("VirtualDub.audio.SetMode(0);\n")
+		b.WriteString("VirtualDub.audio.SetCompression();\n")
+	} else {
+		b.WriteString("VirtualDub.audio.SetSource(0);\n")
+	}
+
+	b.WriteString("VirtualDub.video.SetMode(3);\n")
+	code := j.Preset.FourCC
+	if j.Intermediate != LosslessNone {
+		code = j.Intermediate.FourCC()
+	}
+	if code != "" {
+		fmt.Fprintf(&b, "VirtualDub.video.SetCompression(0x%08x,0,10000,0);\n", fourCC(code))
+	} else {
+		b.WriteString("VirtualDub.video.SetCompression();\n")
+	}
+	fmt.Fprintf(&b, "VirtualDub.SaveAVI(%s);\n", vdString(j.scriptPath(j.IntermediatePath())))
+	b.WriteString("VirtualDub.Close();\n")
+	return b.String()
+}
+
+// VirtualDubBackend renders the AviSynth+ script with VirtualDub2 into the
+// intermediate AVI and muxes it with ffmpeg into the output container. In
+// two-stage mode VirtualDub2 renders the lossless intermediate, which ffmpeg
+// encodes with the preset.
+type VirtualDubBackend struct {
+	Tools
+}
+
+// Name returns the name of the backend.
+func (b *VirtualDubBackend) Name() string {
+	return BackendVirtualDub
+}
+
+// Check returns an error if the job can't be converted. The AviSynth+
+// plugins of the script have to be installed. VirtualDub2 needs a VfW
+// codec for the preset and can't run two passes from a script, unless
+// ffmpeg encodes the lossless intermediate in two-stage mode. Under Wine
+// only one audio track can be rendered by AviSynth+. The metrics
+// read the AviSynth+ script, which ffmpeg can't open with translated paths.
+func (b *VirtualDubBackend) Check(j *Job) error {
+	if b.VirtualDub == "" {
+		return fmt.Errorf("VirtualDub2 path not configured")
+	}
+	if b.FFmpeg == "" {
+		return fmt.Errorf("ffmpeg path not configured")
+	}
+	// the sidecar subtitles are extracted by ffmpeg
+	if err := checkSubtitles(j); err != nil {
+		return err
+	}
+	if j.Frameserver == FrameserverAviSynth {
+		if err := CheckPlugins(j, b.AviSynthPlugins); err != nil {
+			return err
+		}
+		// the scripts of the additional tracks are read by the native ffmpeg
+		if b.Wine.Enabled() && len(j.SelectedAudio()) > 1 {
+			return fmt.Errorf("%s: additional audio tracks need a native AviSynth+, "+
+				"under Wine select one track or use VapourSynth", j.Source.Name)
+		}
+	}
+	if j.Intermediate != LosslessNone {
+		// stage two is encoded by ffmpeg
+		if err := checkEncoder(j); err != nil {
+			return err
+		}
+		return j.Audio.Validate(j.Container)
+	}
+	if j.Preset.FourCC == "" {
+		return fmt.Errorf("preset %q has no VirtualDub2 codec, use the ffmpeg backend", j.Preset.Name)
+	}
+	if j.Preset.TwoPass() {
+		return fmt.Errorf("two-pass encoding of %q needs the ffmpeg backend or two-stage mode", j.Preset.Name)
+	}
+	if j.Analyze && j.Frameserver == FrameserverAviSynth && len(b.scriptPaths()) > 0 {
+		return fmt.Errorf("quality metrics with translated script paths need two-stage mode")
+	}
+	return j.Audio.Validate(j.Container)
+}
+
+// renderCommand returns the command running the VirtualDub2 script of the
+// job and exiting, through Wine if it is enabled.
+func (b *VirtualDubBackend) renderCommand(ctx context.Context, j *Job, script string) *exec.Cmd {
+	return b.Wine.Command(ctx, b.VirtualDub, "/s", j.scriptPath(script), "/x")
+}
+
+// Run writes the scripts, renders them with VirtualDub2 and muxes the
+// result. VirtualDub2 reports no progress, the render stage is reported
+// at start only.
+func (b *VirtualDubBackend) Run(ctx context.Context, j *Job, report func(Progress)) error {
+	// the scripts are written for VirtualDub2, which may run under Wine
+	j.Paths = b.scriptPaths()
+	if err := b.Check(j); err != nil {
+		return err
+	}
+	if err := prepareDirs(j); err != nil {
+		return err
+	}
+	scripts, err := WriteScripts(j, b.Tools)
+	if err != nil {
+		return err
+	}
+
+	render := func() error {
+		if report != nil {
+			report(Progress{Stage: "render", Percent: -1})
+		}
+		out, err := b.renderCommand(ctx, j, scripts.VirtualDub).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("render: %w: %s", err, lastLines(string(out), 3))
+		}
+		return nil
+	}
+
+	if j.Intermediate == LosslessNone {
+		j.Workspace.Track(j.IntermediatePath())
+		if err := render(); err != nil {
+			return err
+		}
+		if err := runFFmpeg(ctx, b.FFmpeg, MuxArgs(j, scripts), "mux", j.OutputDuration(), report); err != nil {
+			return err
+		}
+	} else {
+		key, err := stageOneKey(j, b.Name(), []string{scripts.Source(), scripts.VirtualDub}, nil)
+		if err != nil {
+			return err
+		}
+		if err := renderStageOne(j, key, render, report); err != nil {
+			return err
+		}
+		if j.Preset.TwoPass() {
+			trackPassLogs(j)
+			args := FirstPassArgs(j, []string{"-i", j.LosslessPath()})
+			if err := runFFmpeg(ctx, b.FFmpeg, args, "pass 1", j.OutputDuration(), report); err != nil {
+				return err
+			}
+		}
+		if err := runFFmpeg(ctx, b.FFmpeg, MuxArgs(j, scripts), "encode", j.OutputDuration(), report); err != nil {
+			return err
+		}
+	}
+	if err := extractSidecars(ctx, b.FFmpeg, j, report); err != nil {
+		return err
+	}
+	if err := verify(ctx, b.FFmpeg, j, report); err != nil {
+		return err
+	}
+	if err := analyze(ctx, b.Tools, b.Name(), j, report); err != nil {
+		return err
+	}
+	cleanup(j)
+	return nil
+}
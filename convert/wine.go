@@ -0,0 +1,38 @@
+package convert
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Wine runs the Windows programs of the VirtualDub2 backend, VirtualDub2
+// with AviSynth+, on Linux. ffmpeg and vspipe run natively. The paths in
+// the scripts are mapped to the Z: drive, see PathMap.
+type Wine struct {
+	Launcher string // wine executable, empty runs the programs natively
+	Prefix   string // WINEPREFIX, empty for the default prefix
+}
+
+// Enabled returns true if the programs are run through Wine.
+func (w Wine) Enabled() bool {
+	return w.Launcher != ""
+}
+
+// Command returns the command running the Windows program, through Wine
+// with the prefix if Wine is enabled. An absolute program path is passed
+// to Wine on the Z: drive, the arguments have to be translated already.
+func (w Wine) Command(ctx context.Context, program string, args ...string) *exec.Cmd {
+	if !w.Enabled() {
+		return exec.CommandContext(ctx, program, args...)
+	}
+	if filepath.IsAbs(program) {
+		program = PathMap{wineRoot}.Translate(program)
+	}
+	cmd := exec.CommandContext(ctx, w.Launcher, append([]string{program}, args...)...)
+	if w.Prefix != "" {
+		cmd.Env = append(os.Environ(), "WINEPREFIX="+w.Prefix)
+	}
+	return cmd
+}
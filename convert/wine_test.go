@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubLauncher writes a shell script printing its arguments, one per line,
+// and the Wine prefix of the environment.
+func stubLauncher(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stub launcher needs a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "wine")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" \"prefix=${WINEPREFIX-unset}\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// the prefix of the test environment must not leak into the command
+	t.Setenv("WINEPREFIX", "")
+	os.Unsetenv("WINEPREFIX")
+	return path
+}
+
+// runLines runs the command and returns the lines of its output.
+func runLines(t *testing.T, args []string, out []byte, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%v: %v: %s", args, err, out)
+	}
+	return strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+}
+
+func TestWineCommand(t *testing.T) {
+	launcher := stubLauncher(t)
+	tests := []struct {
+		name   string
+		prefix string
+		want   string
+	}{
+		{"default prefix", "", "prefix=unset"},
+		{"own prefix", "/home/user/.wine-vdub", "prefix=/home/user/.wine-vdub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Wine{Launcher: launcher, Prefix: tt.prefix}
+			cmd := w.Command(context.Background(), "/opt/VirtualDub2/VirtualDub64.exe", "/x")
+			out, err := cmd.CombinedOutput()
+			got := runLines(t, cmd.Args, out, err)
+			want := []string{`Z:\opt\VirtualDub2\VirtualDub64.exe`, "/x", tt.want}
+			if !slices.Equal(got, want) {
+				t.Errorf("output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRenderCommand(t *testing.T) {
+	launcher := stubLauncher(t)
+	b := &VirtualDubBackend{Tools{
+		VirtualDub: "/opt/Virtual Dub2/VirtualDub64.exe",
+		Wine:       Wine{Launcher: launcher},
+	}}
+	j := &Job{Paths: b.scriptPaths()}
+	cmd := b.renderCommand(context.Background(), j, "/tmp/work dir/clip ü.vdscript")
+	out, err := cmd.CombinedOutput()
+	got := runLines(t, cmd.Args, out, err)
+	want := []string{
+		`Z:\opt\Virtual Dub2\VirtualDub64.exe`,
+		"/s",
+		`Z:\tmp\work dir\clip ü.vdscript`,
+		"/x",
+		"prefix=unset",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestWineCommandDisabled(t *testing.T) {
+	program := stubLauncher(t)
+	var w Wine
+	if w.Enabled() {
+		t.Fatal("zero Wine is enabled")
+	}
+	cmd := w.Command(context.Background(), program, "/s", "/tmp/clip.vdscript")
+	out, err := cmd.CombinedOutput()
+	got := runLines(t, cmd.Args, out, err)
+	want := []string{"/s", "/tmp/clip.vdscript", "prefix=unset"}
+	if !slices.Equal(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package convert
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Workspace keeps track of the intermediate files a job creates in the work
+// directory. Files named by the external tools, like the pass logs of the
+// encoders, are tracked with glob patterns.
+type Workspace struct {
+	files    []string
+	patterns []string
+}
+
+// Track adds the file to the workspace and returns its path.
+func (w *Workspace) Track(path string) string {
+	if !slices.Contains(w.files, path) {
+		w.files = append(w.files, path)
+	}
+	return path
+}
+
+// TrackPattern adds the files matching the glob pattern to the workspace.
+func (w *Workspace) TrackPattern(pattern string) {
+	if !slices.Contains(w.patterns, pattern) {
+		w.patterns = append(w.patterns, pattern)
+	}
+}
+
+// Files returns the existing files of the workspace.
+func (w *Workspace) Files() []string {
+	var files []string
+	for _, f := range w.files {
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	for _, p := range w.patterns {
+		matches, _ := filepath.Glob(p)
+		for _, m := range matches {
+			if !slices.Contains(files, m) {
+				files = append(files, m)
+			}
+		}
+	}
+	return files
+}
+
+// Clean deletes the files of the workspace. All files are tried, the
+// errors are returned together.
+func (w *Workspace) Clean() error {
+	var errs []error
+	for _, f := range w.Files() {
+		if err := os.Remove(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeWorkFile writes an intermediate file and tracks it in the workspace.
+func (j *Job) writeWorkFile(path, content string) error {
+	j.Workspace.Track(path)
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// IndexPath returns the index file L-SMASH Works writes for the source.
+func (j *Job) IndexPath() string {
+	return j.WorkPath(".lwi")
+}
+
+// cleanup deletes the intermediate files of a successful job if the
+// project asks for it. Failed jobs keep them for debugging. Files that
+// can't be deleted are logged, the output is fine anyway.
+func cleanup(j *Job) {
+	if !j.Cleanup {
+		return
+	}
+	if err := j.Workspace.Clean(); err != nil {
+		slog.Warn("cleanup", "file", j.Source.Name, "error", err)
+	}
+}
@@ -0,0 +1,50 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+)
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	script := write("clip.avs")
+	passLog := write("clip.pass-0.log")
+	// a directory with content can't be removed, the other files are
+	// deleted anyway
+	busy := filepath.Join(dir, "clip.busy")
+	if err := os.Mkdir(busy, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write("clip.busy/lock")
+
+	j := &Job{Source: &util.MediaInfo{Name: "clip.avi"}, WorkDir: dir}
+	j.Workspace.Track(script)
+	j.Workspace.Track(busy)
+	j.Workspace.TrackPattern(globEscape(j.PassLogPath()) + "-*.log")
+
+	cleanup(j)
+	if len(j.Workspace.Files()) != 3 {
+		t.Fatalf("cleanup without Cleanup deleted files: %v", j.Workspace.Files())
+	}
+
+	j.Cleanup = true
+	cleanup(j)
+	for _, path := range []string{script, passLog} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not deleted", path)
+		}
+	}
+	if _, err := os.Stat(busy); err != nil {
+		t.Errorf("%s: %v", busy, err)
+	}
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log/slog"
+	"os"
+	"os/signal"
 
 	"github.com/archeopternix/gofltk-videoconverter/ui"
 	"github.com/pwiecz/go-fltk"
@@ -10,11 +15,51 @@ import (
 func main() {
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 
-	window := fltk.NewWindow(500, 440, "Video Enhancer and Converter")
+	if len(os.Args) > 1 && os.Args[1] == "watch" {
+		if err := watch(os.Args[2:]); err != nil {
+			slog.Error("watch", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	window := fltk.NewWindow(600, 440, "Video Enhancer and Converter")
 	window.Resizable(window)
 	app := ui.NewApp(window)
 	app.Hello()
 	window.Show()
 
+	// Enable fltk.Awake for the conversions running in the background
+	fltk.Lock()
+	app.CheckInstallation()
 	fltk.Run()
 }
+
+// watch runs the watch folder mode without window until it is interrupted:
+//
+//	gofltk-videoconverter watch [-system sys.yaml] [-project prj.yaml] [-archive dir] dir...
+func watch(args []string) error {
+	cfg := ui.NewWatchConfig()
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	system := flags.String("system", "", "YAML file with the system configuration")
+	project := flags.String("project", "", "YAML file with the project configuration")
+	flags.StringVar(&cfg.ArchiveDir, "archive", "", "move the sources into this directory after the conversion")
+	flags.DurationVar(&cfg.Settle, "settle", cfg.Settle, "time a file has to stay unchanged before it is converted")
+	flags.DurationVar(&cfg.Interval, "interval", cfg.Interval, "time between two scans of the directories")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gofltk-videoconverter watch [flags] dir...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cfg.Dirs = flags.Args()
+	if len(cfg.Dirs) == 0 {
+		flags.Usage()
+		return fmt.Errorf("no directory to watch")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return ui.RunWatch(ctx, *system, *project, cfg)
+}
@@ -0,0 +1,78 @@
+package mediatime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSeconds parses seconds with fractions like the ffprobe value
+// "1234.567000" into a duration.
+func ParseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid seconds string: %v", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// FirstDuration returns the first valid positive duration of the values.
+// Used for the fallback from the stream duration, which is missing for many
+// containers like MKV, to the container duration:
+//
+//	FirstDuration(stream.Duration, format.Duration)
+func FirstDuration(values ...string) (time.Duration, error) {
+	for _, v := range values {
+		if d, err := ParseSeconds(v); err == nil && d > 0 {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("no valid duration in %q", values)
+}
+
+// FormatDuration returns the duration as "hh:mm:ss.mmm".
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Round(time.Millisecond).Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// FormatHMS returns the duration as "hh:mm:ss", rounded to whole seconds.
+func FormatHMS(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	s := int64(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// ParseDuration parses "hh:mm:ss.mmm", "mm:ss.mmm" or seconds like "90.5"
+// as entered by the user. Hours and minutes are optional.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	seconds, err := ParseSeconds(parts[len(parts)-1])
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	d := seconds
+	unit := time.Minute
+	for i := len(parts) - 2; i >= 0; i-- {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		unit *= 60
+	}
+	return d, nil
+}
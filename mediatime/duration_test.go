@@ -0,0 +1,46 @@
+package mediatime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"stream duration", []string{"10.5", "12.0"}, 10500 * time.Millisecond, false},
+		{"missing stream duration", []string{"", "12.000000"}, 12 * time.Second, false},
+		{"invalid stream duration", []string{"N/A", "12"}, 12 * time.Second, false},
+		{"zero stream duration", []string{"0.000000", "12"}, 12 * time.Second, false},
+		{"no duration", []string{"", "N/A"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FirstDuration(tt.values...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FirstDuration(%q) error = %v, want error %v", tt.values, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FirstDuration(%q) = %v, want %v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFramesDuration(t *testing.T) {
+	// one hour of NTSC video has 107892.1 frames, the rounding must not drift
+	if got := FPS2997.Frames(time.Hour); got != 107892 {
+		t.Errorf("Frames(1h) = %d, want 107892", got)
+	}
+	for _, rate := range []FrameRate{FPS23976, FPS25, FPS2997, FPS5994} {
+		for _, frames := range []int64{0, 1, 1799, 1800, 107892} {
+			if got := rate.Frames(rate.Duration(frames)); got != frames {
+				t.Errorf("%s: Frames(Duration(%d)) = %d", rate, frames, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package mediatime
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// FrameRate is the number of frames per second as exact fraction.
+type FrameRate struct {
+	Rational
+}
+
+// Common frame rates, the NTSC rates are multiples of 1000/1001.
+var (
+	FPS23976 = FrameRate{Rational{24000, 1001}}
+	FPS24    = FrameRate{Rational{24, 1}}
+	FPS25    = FrameRate{Rational{25, 1}}
+	FPS2997  = FrameRate{Rational{30000, 1001}}
+	FPS30    = FrameRate{Rational{30, 1}}
+	FPS50    = FrameRate{Rational{50, 1}}
+	FPS5994  = FrameRate{Rational{60000, 1001}}
+	FPS60    = FrameRate{Rational{60, 1}}
+)
+
+// ParseFrameRate parses a frame rate like "25/1", "30000/1001" or "29.97".
+// Decimal values close to an NTSC rate are snapped to the exact fraction.
+func ParseFrameRate(s string) (FrameRate, error) {
+	r, err := ParseRational(s)
+	if err != nil {
+		return FrameRate{}, err
+	}
+	if r.IsZero() || r.Num < 0 {
+		return FrameRate{}, fmt.Errorf("invalid frame rate: %s", s)
+	}
+
+	if r.Den != 1 && r.Den != 1001 {
+		// snap "29.97", "59.94" or "2997/100" to the exact NTSC fraction
+		ntsc := math.Round(r.Float64()*1001/1000) * 1000
+		if math.Abs(ntsc/1001-r.Float64()) < 0.005 {
+			return FrameRate{NewRational(int64(ntsc), 1001)}, nil
+		}
+	}
+	return FrameRate{r}, nil
+}
+
+// IsNTSC returns true for the NTSC rates 23.976, 29.97, 59.94 and so on.
+func (f FrameRate) IsNTSC() bool {
+	return f.Den == 1001
+}
+
+// Nominal returns the integer frame rate used for timecodes, i.e. 30 for 29.97.
+func (f FrameRate) Nominal() int {
+	return int(math.Round(f.Float64()))
+}
+
+// Double returns the doubled frame rate, e.g. after bob deinterlacing.
+func (f FrameRate) Double() FrameRate {
+	return FrameRate{f.Mul(2)}
+}
+
+// Half returns the halved frame rate.
+func (f FrameRate) Half() FrameRate {
+	return FrameRate{NewRational(f.Num, f.Den*2)}
+}
+
+// FrameDuration returns the duration of a single frame.
+func (f FrameRate) FrameDuration() time.Duration {
+	return f.Duration(1)
+}
+
+// Frames returns the number of frames in the duration, rounded to the
+// nearest frame.
+func (f FrameRate) Frames(d time.Duration) int64 {
+	if f.IsZero() {
+		return 0
+	}
+	// frames = d * num / (den * 1s), computed exactly
+	n := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(f.Num))
+	q := new(big.Int).Mul(big.NewInt(f.Den), big.NewInt(int64(time.Second)))
+	n.Add(n, new(big.Int).Div(q, big.NewInt(2)))
+	return n.Div(n, q).Int64()
+}
+
+// Duration returns the playing time of the number of frames.
+func (f FrameRate) Duration(frames int64) time.Duration {
+	if f.IsZero() {
+		return 0
+	}
+	n := new(big.Int).Mul(big.NewInt(frames), big.NewInt(f.Den*int64(time.Second)))
+	return time.Duration(n.Div(n, big.NewInt(f.Num)).Int64())
+}
+
+// String returns the frame rate with up to 3 decimal digits like "25",
+// "29.97" or "23.976".
+func (f FrameRate) String() string {
+	ret := fmt.Sprintf("%.3f", f.Float64())
+	ret = strings.TrimRight(ret, "0")
+	return strings.TrimSuffix(ret, ".")
+}
+
+// Label returns the frame rate with the scan type suffix, "25p" for
+// progressive and "29.97i" for interlaced video. Without a known field
+// order no suffix is added.
+func (f FrameRate) Label(fo FieldOrder) string {
+	return f.String() + fo.Suffix()
+}
+
+// FieldOrder is the scan type of a video stream as reported by ffprobe.
+type FieldOrder string
+
+const (
+	FieldOrderUnknown     FieldOrder = ""
+	FieldOrderProgressive FieldOrder = "progressive"
+	FieldOrderTT          FieldOrder = "tt" // top field first, top field displayed first
+	FieldOrderBB          FieldOrder = "bb" // bottom field first, bottom field displayed first
+	FieldOrderTB          FieldOrder = "tb" // top field coded first, bottom displayed first
+	FieldOrderBT          FieldOrder = "bt" // bottom field coded first, top displayed first
+)
+
+// ParseFieldOrder converts the ffprobe field_order value, unknown values
+// result in FieldOrderUnknown.
+func ParseFieldOrder(s string) FieldOrder {
+	switch fo := FieldOrder(strings.ToLower(strings.TrimSpace(s))); fo {
+	case FieldOrderProgressive, FieldOrderTT, FieldOrderBB, FieldOrderTB, FieldOrderBT:
+		return fo
+	}
+	return FieldOrderUnknown
+}
+
+// IsInterlaced returns true when the field order is known to be interlaced.
+func (fo FieldOrder) IsInterlaced() bool {
+	switch fo {
+	case FieldOrderTT, FieldOrderBB, FieldOrderTB, FieldOrderBT:
+		return true
+	}
+	return false
+}
+
+// TopFieldFirst returns true when the top field is displayed first.
+func (fo FieldOrder) TopFieldFirst() bool {
+	return fo == FieldOrderTT || fo == FieldOrderBT
+}
+
+// Suffix returns "p" for progressive, "i" for interlaced and "" otherwise.
+func (fo FieldOrder) Suffix() string {
+	switch {
+	case fo == FieldOrderProgressive:
+		return "p"
+	case fo.IsInterlaced():
+		return "i"
+	}
+	return ""
+}
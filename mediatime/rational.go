@@ -0,0 +1,104 @@
+// Package mediatime provides typed frame rates, frame counts, SMPTE
+// timecodes and durations for video files.
+//
+// Frame rates are kept as exact fractions like ffprobe reports them
+// ("30000/1001"), so NTSC rates are not rounded and frame numbers computed
+// from durations stay exact over long recordings.
+package mediatime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rational is an exact fraction Num/Den.
+type Rational struct {
+	Num int64
+	Den int64
+}
+
+// NewRational returns the reduced fraction num/den with a positive denominator.
+func NewRational(num, den int64) Rational {
+	if den < 0 {
+		num, den = -num, -den
+	}
+	if g := gcd(abs(num), den); g > 1 {
+		num, den = num/g, den/g
+	}
+	return Rational{Num: num, Den: den}
+}
+
+// ParseRational parses fractions like "30000/1001", integers like "25" and
+// decimal numbers like "29.97".
+func ParseRational(s string) (Rational, error) {
+	s = strings.TrimSpace(s)
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+		if err != nil {
+			return Rational{}, fmt.Errorf("invalid numerator: %v", err)
+		}
+		d, err := strconv.ParseInt(strings.TrimSpace(den), 10, 64)
+		if err != nil {
+			return Rational{}, fmt.Errorf("invalid denominator: %v", err)
+		}
+		if d == 0 {
+			return Rational{}, fmt.Errorf("division by zero is not allowed: %s", s)
+		}
+		return NewRational(n, d), nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Rational{Num: n, Den: 1}, nil
+	}
+
+	// decimal number, keep up to 6 digits after the decimal point
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Rational{}, fmt.Errorf("invalid input format: %s", s)
+	}
+	const scale = 1000000
+	return NewRational(int64(f*scale+0.5), scale), nil
+}
+
+// IsZero returns true for zero or undefined fractions like ffprobe's "0/0".
+func (r Rational) IsZero() bool {
+	return r.Num == 0 || r.Den == 0
+}
+
+// Float64 returns the value of the fraction.
+func (r Rational) Float64() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+// Mul returns the product of the fraction and n.
+func (r Rational) Mul(n int64) Rational {
+	return NewRational(r.Num*n, r.Den)
+}
+
+// Equal returns true when both fractions have the same value.
+func (r Rational) Equal(o Rational) bool {
+	return r.Num*o.Den == o.Num*r.Den
+}
+
+// String returns the fraction in the ffprobe notation "num/den".
+func (r Rational) String() string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func abs(a int64) int64 {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
@@ -0,0 +1,62 @@
+package mediatime
+
+import "testing"
+
+func TestParseRational(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rational
+		wantErr bool
+	}{
+		{"30000/1001", Rational{30000, 1001}, false},
+		{" 25/1 ", Rational{25, 1}, false},
+		{"50/2", Rational{25, 1}, false},
+		{"25", Rational{25, 1}, false},
+		{"29.97", Rational{2997, 100}, false},
+		{"0/0", Rational{}, true},
+		{"unknown", Rational{}, true},
+		{"", Rational{}, true},
+		{"30000/x", Rational{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRational(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRational(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRational(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFrameRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    FrameRate
+		wantErr bool
+	}{
+		{"30000/1001", FPS2997, false},
+		{"24000/1001", FPS23976, false},
+		{"29.97", FPS2997, false},
+		{"59.94", FPS5994, false},
+		{"2997/100", FPS2997, false},
+		{"25/1", FPS25, false},
+		{"0/0", FrameRate{}, true},
+		{"0/1", FrameRate{}, true},
+		{"-25/1", FrameRate{}, true},
+		{"unknown", FrameRate{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseFrameRate(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFrameRate(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFrameRate(%q) = %v, want %v", tt.in, got.Rational, tt.want.Rational)
+			}
+		})
+	}
+}
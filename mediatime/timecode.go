@@ -0,0 +1,164 @@
+package mediatime
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timecode is a SMPTE timecode hh:mm:ss:ff. Drop-frame timecodes (written
+// with ";" before the frames) skip frame numbers at the start of every
+// minute except every tenth, so that the timecode follows the wall clock
+// for the NTSC rates 29.97 and 59.94.
+type Timecode struct {
+	Hours     int
+	Minutes   int
+	Seconds   int
+	Frames    int
+	DropFrame bool
+}
+
+// dropFrames returns the number of frame numbers skipped per minute, 2 for
+// 29.97 and 4 for 59.94. SMPTE defines drop-frame only for the NTSC rates
+// with a nominal rate of a multiple of 30, 23.976 has none.
+func dropFrames(rate FrameRate) int64 {
+	nominal := rate.Nominal()
+	if !rate.IsNTSC() || nominal%30 != 0 {
+		return 0
+	}
+	return int64(nominal / 15)
+}
+
+// HasDropFrame returns true if the rate has a drop-frame timecode.
+func HasDropFrame(rate FrameRate) bool {
+	return dropFrames(rate) > 0
+}
+
+// FromFrames converts a frame number into a timecode. Drop-frame counting
+// is only applied for the rates with drop-frame timecode, see HasDropFrame.
+func FromFrames(frame int64, rate FrameRate, dropFrame bool) Timecode {
+	nominal := int64(rate.Nominal())
+	if nominal <= 0 {
+		return Timecode{}
+	}
+	drop := int64(0)
+	if dropFrame {
+		drop = dropFrames(rate)
+	}
+
+	if drop > 0 {
+		framesPer10Min := nominal*600 - drop*9
+		framesPerMin := nominal*60 - drop
+		tens := frame / framesPer10Min
+		rest := frame % framesPer10Min
+		frame += drop * 9 * tens
+		if rest > drop {
+			frame += drop * ((rest - drop) / framesPerMin)
+		}
+	}
+
+	return Timecode{
+		Hours:     int(frame / (nominal * 3600)),
+		Minutes:   int(frame / (nominal * 60) % 60),
+		Seconds:   int(frame / nominal % 60),
+		Frames:    int(frame % nominal),
+		DropFrame: drop > 0,
+	}
+}
+
+// ToFrames converts the timecode back into a frame number.
+func (t Timecode) ToFrames(rate FrameRate) int64 {
+	nominal := int64(rate.Nominal())
+	frames := (int64(t.Hours)*3600+int64(t.Minutes)*60+int64(t.Seconds))*nominal + int64(t.Frames)
+	if t.DropFrame {
+		minutes := int64(t.Hours)*60 + int64(t.Minutes)
+		frames -= dropFrames(rate) * (minutes - minutes/10)
+	}
+	return frames
+}
+
+// ParseTimecode parses "hh:mm:ss:ff" and the drop-frame notation "hh:mm:ss;ff".
+// The frame rate is not known here, use Validate to check the timecode for
+// the rate of the video.
+func ParseTimecode(s string) (Timecode, error) {
+	s = strings.TrimSpace(s)
+	t := Timecode{DropFrame: strings.ContainsAny(s, ";,")}
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ':' || r == ';' || r == ',' || r == '.'
+	})
+	if len(parts) != 4 {
+		return Timecode{}, fmt.Errorf("invalid timecode: %s", s)
+	}
+
+	values := make([]int, 4)
+	for i, p := range parts {
+		v, err := strconv.Atoi(p)
+		if err != nil || v < 0 {
+			return Timecode{}, fmt.Errorf("invalid timecode: %s", s)
+		}
+		values[i] = v
+	}
+	t.Hours, t.Minutes, t.Seconds, t.Frames = values[0], values[1], values[2], values[3]
+	if t.Minutes > 59 || t.Seconds > 59 {
+		return Timecode{}, fmt.Errorf("invalid timecode: %s", s)
+	}
+	return t, nil
+}
+
+// Validate returns an error if the timecode doesn't exist at the rate: the
+// frames exceed the nominal rate, the rate has no drop-frame timecode or
+// the frame number is skipped by drop-frame counting.
+func (t Timecode) Validate(rate FrameRate) error {
+	nominal := rate.Nominal()
+	if nominal <= 0 {
+		return fmt.Errorf("invalid frame rate: %s", rate.Rational)
+	}
+	if t.Frames >= nominal {
+		return fmt.Errorf("timecode %s: frame %d at %s fps", t, t.Frames, rate)
+	}
+	if !t.DropFrame {
+		return nil
+	}
+	drop := dropFrames(rate)
+	if drop == 0 {
+		return fmt.Errorf("timecode %s: no drop-frame timecode at %s fps", t, rate)
+	}
+	if t.Seconds == 0 && t.Minutes%10 != 0 && int64(t.Frames) < drop {
+		return fmt.Errorf("timecode %s: frame number dropped", t)
+	}
+	return nil
+}
+
+// String returns the timecode as "hh:mm:ss:ff" or "hh:mm:ss;ff" for drop-frame.
+func (t Timecode) String() string {
+	sep := ":"
+	if t.DropFrame {
+		sep = ";"
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%02d", t.Hours, t.Minutes, t.Seconds, sep, t.Frames)
+}
+
+// FormatTimecode returns the timecode of the frame at the position, with
+// drop-frame counting if the rate has a drop-frame timecode.
+func FormatTimecode(d time.Duration, rate FrameRate) string {
+	return FromFrames(rate.Frames(d), rate, HasDropFrame(rate)).String()
+}
+
+// ParsePosition parses a position in the video entered by the user, as
+// duration like ParseDuration or as timecode "hh:mm:ss:ff" or "hh:mm:ss;ff"
+// at the frame rate of the video.
+func ParsePosition(s string, rate FrameRate) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if strings.Count(s, ":") < 3 && !strings.ContainsAny(s, ";,") {
+		return ParseDuration(s)
+	}
+	t, err := ParseTimecode(s)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.Validate(rate); err != nil {
+		return 0, err
+	}
+	return rate.Duration(t.ToFrames(rate)), nil
+}
@@ -0,0 +1,178 @@
+package mediatime
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDropFrames(t *testing.T) {
+	tests := []struct {
+		rate FrameRate
+		want int64
+	}{
+		{FPS2997, 2},
+		{FPS5994, 4},
+		{FPS23976, 0},
+		{FPS25, 0},
+		{FPS30, 0},
+		{FPS60, 0},
+	}
+	for _, tt := range tests {
+		if got := dropFrames(tt.rate); got != tt.want {
+			t.Errorf("dropFrames(%s) = %d, want %d", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestFromFrames(t *testing.T) {
+	tests := []struct {
+		name      string
+		frame     int64
+		rate      FrameRate
+		dropFrame bool
+		want      string
+	}{
+		{"29.97 start", 0, FPS2997, true, "00:00:00;00"},
+		{"29.97 before minute 1", 1799, FPS2997, true, "00:00:59;29"},
+		{"29.97 minute 1 skips 00 and 01", 1800, FPS2997, true, "00:01:00;02"},
+		{"29.97 minute 2", 3598, FPS2997, true, "00:02:00;02"},
+		{"29.97 before minute 10", 17981, FPS2997, true, "00:09:59;29"},
+		{"29.97 minute 10 drops nothing", 17982, FPS2997, true, "00:10:00;00"},
+		{"29.97 minute 11", 19782, FPS2997, true, "00:11:00;02"},
+		{"29.97 one hour", 107892, FPS2997, true, "01:00:00;00"},
+		{"29.97 non drop", 1800, FPS2997, false, "00:01:00:00"},
+		{"59.94 minute 1 skips 00 to 03", 3600, FPS5994, true, "00:01:00;04"},
+		{"59.94 before minute 10", 35963, FPS5994, true, "00:09:59;59"},
+		{"59.94 minute 10", 35964, FPS5994, true, "00:10:00;00"},
+		{"23.976 has no drop-frame", 1440, FPS23976, true, "00:01:00:00"},
+		{"25", 1500, FPS25, false, "00:01:00:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := FromFrames(tt.frame, tt.rate, tt.dropFrame)
+			if got := tc.String(); got != tt.want {
+				t.Errorf("FromFrames(%d) = %s, want %s", tt.frame, got, tt.want)
+			}
+			if back := tc.ToFrames(tt.rate); back != tt.frame {
+				t.Errorf("%s.ToFrames() = %d, want %d", tc, back, tt.frame)
+			}
+		})
+	}
+}
+
+func TestDropFrameRoundTrip(t *testing.T) {
+	for _, rate := range []FrameRate{FPS2997, FPS5994} {
+		// every frame of the first 11 minutes crosses all minute boundaries
+		end := int64(rate.Nominal()) * 60 * 11
+		for frame := range end {
+			tc := FromFrames(frame, rate, true)
+			if err := tc.Validate(rate); err != nil {
+				t.Fatalf("frame %d at %s: %v", frame, rate, err)
+			}
+			if back := tc.ToFrames(rate); back != frame {
+				t.Fatalf("frame %d at %s: %s.ToFrames() = %d", frame, rate, tc, back)
+			}
+		}
+	}
+}
+
+func TestParseTimecode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Timecode
+		wantErr bool
+	}{
+		{"01:02:03:04", Timecode{1, 2, 3, 4, false}, false},
+		{"01:02:03;04", Timecode{1, 2, 3, 4, true}, false},
+		{"01:02:03,04", Timecode{1, 2, 3, 4, true}, false},
+		{"01:60:03:04", Timecode{}, true},
+		{"01:02:03", Timecode{}, true},
+		{"aa:02:03:04", Timecode{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseTimecode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimecode(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimecode(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		in      string
+		rate    FrameRate
+		wantErr bool
+	}{
+		{"00:01:00;02", FPS2997, false},
+		{"00:01:00;01", FPS2997, true},
+		{"00:10:00;00", FPS2997, false},
+		{"00:01:00;03", FPS5994, true},
+		{"00:01:00;04", FPS5994, false},
+		{"00:01:00;02", FPS23976, true},
+		{"00:01:00;02", FPS25, true},
+		{"00:00:00:25", FPS25, true},
+		{"00:00:00:24", FPS25, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in+"@"+tt.rate.String(), func(t *testing.T) {
+			tc, err := ParseTimecode(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tc.Validate(tt.rate); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatTimecode(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		rate FrameRate
+		want string
+	}{
+		{0, FPS25, "00:00:00:00"},
+		{90*time.Second + 480*time.Millisecond, FPS25, "00:01:30:12"},
+		{time.Minute, FPS2997, "00:00:59;28"},
+		{time.Hour, FPS2997, "01:00:00;00"},
+		{time.Minute, FPS23976, "00:00:59:23"},
+	}
+	for _, tt := range tests {
+		if got := FormatTimecode(tt.d, tt.rate); got != tt.want {
+			t.Errorf("FormatTimecode(%v, %s) = %s, want %s", tt.d, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		in      string
+		rate    FrameRate
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", FPS25, 0, false},
+		{"90.5", FPS25, 90*time.Second + 500*time.Millisecond, false},
+		{"01:30.5", FPS25, 90*time.Second + 500*time.Millisecond, false},
+		{"00:01:30:12", FPS25, 90*time.Second + 480*time.Millisecond, false},
+		{"01:00:00;00", FPS2997, FPS2997.Duration(107892), false},
+		{"00:01:00;02", FPS2997, FPS2997.Duration(1800), false},
+		{"00:01:00;01", FPS2997, 0, true},
+		{"00:00:00:25", FPS25, 0, true},
+		{"00:00:01;00", FPS25, 0, true},
+		{"00:00:01:00", FrameRate{}, 0, true},
+		{"1:2:3:4:5", FPS25, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePosition(tt.in, tt.rate)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePosition(%q, %s) = %v, %v, want %v, error %v", tt.in, tt.rate, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
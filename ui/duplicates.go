@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/util"
+	"github.com/pwiecz/go-fltk"
+)
+
+// maxDuplicateLines limits the duplicates listed in the warning.
+const maxDuplicateLines = 10
+
+// findDuplicates returns the files of paths with the same content as a
+// file of the list, a file in the output directory or an earlier file of
+// paths, mapped to that file.
+func findDuplicates(paths []string, listed []*Item, outputDir string) map[string]string {
+	known := util.ListFiles(outputDir)
+	for _, item := range listed {
+		known = append(known, item.info.FullPath)
+	}
+	return util.FindDuplicates(paths, known)
+}
+
+// skipDuplicates warns about files with the same content as a file of the
+// list or the output directory and returns the files to import, without the
+// duplicates if the user chooses to skip them.
+func (a *App) skipDuplicates(paths []string) []string {
+	duplicates := findDuplicates(paths, a.lister.Items(), a.projectconfig.OutputDir)
+	if len(duplicates) == 0 {
+		return paths
+	}
+
+	var lines []string
+	for _, path := range paths {
+		original, ok := duplicates[path]
+		if !ok {
+			continue
+		}
+		slog.Info("duplicate file", "file", path, "original", original)
+		if len(lines) < maxDuplicateLines {
+			lines = append(lines, filepath.Base(path)+" = "+original)
+		}
+	}
+	if len(duplicates) > len(lines) {
+		lines = append(lines, fmt.Sprintf("and %d more", len(duplicates)-len(lines)))
+	}
+	msg := fmt.Sprintf("%d files have the same content as a file in the list or in the output directory:\n%s",
+		len(duplicates), strings.Join(lines, "\n"))
+	if fltk.ChoiceDialog(msg, "Skip duplicates", "Keep all") != 0 {
+		return paths
+	}
+
+	var unique []string
+	for _, path := range paths {
+		if _, ok := duplicates[path]; !ok {
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,256 @@
+package ui
+
+import (
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/pwiecz/go-fltk"
+)
+
+// FilterDialog creates and displays a modal dialog to edit the video
+// filter chain of the project. Filters are added, removed, moved and
+// enabled in the list, the parameters of the selected filter are edited
+// on the right. Named chains are saved as YAML files in the preset
+// directory and can be loaded into the chain.
+func (p *ProjectConfig) FilterDialog(presetDir string) {
+	dialog := fltk.NewWindow(700, 460, "Video Filters")
+	dialog.SetModal() // Set the window as modal
+	dialog.Begin()
+
+	// Edited copy of the chain, applied on save
+	chain := p.Filters.Clone()
+
+	mainBox := fltk.NewGroup(0, 0, dialog.W(), dialog.H())
+	dialog.Add(mainBox)
+
+	// Filter chain, the line of a filter is its index + 1
+	list := fltk.NewHoldBrowser(10, 10, 250, 300)
+	mainBox.Add(list)
+
+	// Parameters of the selected filter, rebuilt on selection
+	paramBox := fltk.NewBox(fltk.ENGRAVED_BOX, 270, 10, 420, 300, "")
+	paramBox.SetAlign(fltk.ALIGN_TOP | fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE)
+	mainBox.Add(paramBox)
+	params := fltk.NewGroup(275, 40, 410, 265)
+	params.End()
+	mainBox.Add(params)
+
+	var showParams func()
+	fillList := func(selected int) {
+		list.Clear()
+		for _, f := range chain {
+			label := f.Name
+			if !f.Enabled {
+				label = "@i" + label + " (off)"
+			}
+			list.Add(label)
+		}
+		if selected >= 0 && selected < len(chain) {
+			list.SetValue(selected + 1)
+		}
+		showParams()
+	}
+	selected := func() int {
+		return list.Value() - 1
+	}
+
+	showParams = func() {
+		for _, w := range params.Children() {
+			params.Remove(w)
+			w.Destroy()
+		}
+		i := selected()
+		if i < 0 || i >= len(chain) {
+			paramBox.SetLabel("No filter selected")
+			params.Redraw()
+			paramBox.Redraw()
+			return
+		}
+		f := &chain[i]
+		def, ok := convert.LookupFilter(f.Name)
+		if !ok {
+			paramBox.SetLabel("Unknown filter " + f.Name)
+			params.Redraw()
+			paramBox.Redraw()
+			return
+		}
+		paramBox.SetLabel(def.Name + ": " + def.Description)
+		values := f.Values()
+
+		params.Begin()
+		for row, param := range def.Params {
+			y := 40 + row*40
+			label := fltk.NewBox(fltk.NO_BOX, 280, y, 120, 30, param.Label)
+			label.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+			switch param.Kind {
+			case convert.ParamRange:
+				slider := fltk.NewValueSlider(400, y, 280, 30)
+				slider.SetType(fltk.HOR_NICE_SLIDER)
+				slider.SetMinimum(param.Min)
+				slider.SetMaximum(param.Max)
+				slider.SetStep(param.Step)
+				value, _ := strconv.ParseFloat(values[param.Name], 64)
+				slider.SetValue(value)
+				slider.SetCallback(func() {
+					f.Params[param.Name] = strconv.FormatFloat(slider.Value(), 'f', -1, 64)
+				})
+			case convert.ParamEnum:
+				choice := fltk.NewChoice(400, y, 200, 30, "")
+				for _, option := range param.Options {
+					choice.Add(option, func() {
+						f.Params[param.Name] = option
+					})
+				}
+				choice.SetValue(max(0, slices.Index(param.Options, values[param.Name])))
+			case convert.ParamBool:
+				check := fltk.NewCheckButton(400, y, 200, 30, "")
+				check.SetValue(values.Bool(param.Name))
+				check.SetCallback(func() {
+					f.Params[param.Name] = strconv.FormatBool(check.Value())
+				})
+			}
+		}
+		params.End()
+		params.Redraw()
+		paramBox.Redraw()
+	}
+	list.SetCallback(showParams)
+
+	// Filter to add at the end of the chain
+	addChoice := fltk.NewChoice(10, 320, 150, 30, "")
+	names := convert.FilterNames()
+	for _, name := range names {
+		addChoice.Add(name, func() {})
+	}
+	addChoice.SetValue(0)
+	addBtn := fltk.NewButton(170, 320, 90, 30, "Add")
+	addBtn.SetCallback(func() {
+		chain = append(chain, convert.NewFilter(names[addChoice.Value()]))
+		fillList(len(chain) - 1)
+	})
+	mainBox.Add(addChoice)
+	mainBox.Add(addBtn)
+
+	removeBtn := fltk.NewButton(10, 360, 60, 30, "Remove")
+	removeBtn.SetCallback(func() {
+		if i := selected(); i >= 0 && i < len(chain) {
+			chain = slices.Delete(chain, i, i+1)
+			fillList(min(i, len(chain)-1))
+		}
+	})
+	upBtn := fltk.NewButton(75, 360, 60, 30, "@8>")
+	upBtn.SetTooltip("Move up")
+	upBtn.SetCallback(func() {
+		if i := selected(); i > 0 && i < len(chain) {
+			chain[i-1], chain[i] = chain[i], chain[i-1]
+			fillList(i - 1)
+		}
+	})
+	downBtn := fltk.NewButton(140, 360, 60, 30, "@2>")
+	downBtn.SetTooltip("Move down")
+	downBtn.SetCallback(func() {
+		if i := selected(); i >= 0 && i < len(chain)-1 {
+			chain[i+1], chain[i] = chain[i], chain[i+1]
+			fillList(i + 1)
+		}
+	})
+	enableBtn := fltk.NewButton(205, 360, 55, 30, "On/Off")
+	enableBtn.SetTooltip("Enable or disable the filter")
+	enableBtn.SetCallback(func() {
+		if i := selected(); i >= 0 && i < len(chain) {
+			chain[i].Enabled = !chain[i].Enabled
+			fillList(i)
+		}
+	})
+	mainBox.Add(removeBtn)
+	mainBox.Add(upBtn)
+	mainBox.Add(downBtn)
+	mainBox.Add(enableBtn)
+
+	// Named filter chains in the preset directory
+	presetBox := fltk.NewBox(fltk.NO_BOX, 270, 320, 60, 30, "Preset")
+	presetBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	presetChoice := fltk.NewInputChoice(330, 320, 200, 30)
+	presetChoice.SetTooltip("Select a saved filter chain or type the name to save the chain as")
+	var presets []convert.FilterPreset
+	loadPresets := func() {
+		var err error
+		presets, err = convert.LoadFilterPresets(presetDir)
+		if err != nil {
+			slog.Error("load filter presets", "dir", presetDir, "error", err)
+		}
+		presetChoice.Clear()
+		for _, preset := range presets {
+			presetChoice.MenuButton().Add(preset.Name, func() {
+				presetChoice.Input().SetValue(preset.Name)
+			})
+		}
+	}
+	loadPresets()
+
+	loadBtn := fltk.NewButton(540, 320, 45, 30, "Load")
+	loadBtn.SetCallback(func() {
+		name := presetChoice.Value()
+		for _, preset := range presets {
+			if preset.Name == name {
+				chain = preset.Filters.Clone()
+				fillList(0)
+				return
+			}
+		}
+		fltk.MessageBox("Video Filters", "unknown filter preset "+name)
+	})
+	savePresetBtn := fltk.NewButton(590, 320, 45, 30, "Save")
+	savePresetBtn.SetTooltip("Save the chain as preset")
+	savePresetBtn.SetCallback(func() {
+		preset := convert.FilterPreset{Name: presetChoice.Value(), Filters: chain}
+		if err := convert.SaveFilterPreset(presetDir, preset); err != nil {
+			fltk.MessageBox("Video Filters", err.Error())
+			return
+		}
+		loadPresets()
+	})
+	deletePresetBtn := fltk.NewButton(640, 320, 50, 30, "Delete")
+	deletePresetBtn.SetCallback(func() {
+		if err := convert.DeleteFilterPreset(presetDir, presetChoice.Value()); err != nil {
+			fltk.MessageBox("Video Filters", err.Error())
+			return
+		}
+		presetChoice.Input().SetValue("")
+		loadPresets()
+	})
+	mainBox.Add(presetBox)
+	mainBox.Add(presetChoice)
+	mainBox.Add(loadBtn)
+	mainBox.Add(savePresetBtn)
+	mainBox.Add(deletePresetBtn)
+
+	// Bottom Buttons
+	cancelBtn := fltk.NewButton(mainBox.W()/2-110, mainBox.H()-40, 100, 30, "Cancel")
+	saveBtn := fltk.NewButton(mainBox.W()/2+10, mainBox.H()-40, 100, 30, "Save")
+	cancelBtn.SetCallback(func() {
+		dialog.Hide()
+	})
+	saveBtn.SetCallback(func() {
+		if err := chain.Validate(); err != nil {
+			fltk.MessageBox("Video Filters", err.Error())
+			return
+		}
+		slog.Debug("filter chain changed", "filters", chain)
+		p.Filters = chain
+		if p.onSave != nil {
+			p.onSave()
+		}
+		dialog.Hide()
+	})
+	mainBox.Add(cancelBtn)
+	mainBox.Add(saveBtn)
+
+	fillList(0)
+
+	// Finalize the window and display it
+	dialog.End()
+	dialog.Show()
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/pwiecz/go-fltk"
+)
+
+// healthDialog shows the results of the installation check as table with
+// the state, the tool, the version or error and the path.
+func healthDialog(checks []convert.ToolCheck) {
+	dialog := fltk.NewWindow(700, 400, "Installation Check")
+	dialog.SetModal() // Set the window as modal
+	dialog.Begin()
+
+	table := fltk.NewBrowser(10, 10, 680, 340)
+	table.SetColumnChar('\t')
+	table.SetColumnWidths(60, 120, 300, 200)
+	table.Add("@bState\t@bTool\t@bVersion\t@bPath")
+	for _, c := range checks {
+		table.Add(healthLine(c))
+	}
+
+	closeBtn := fltk.NewButton(dialog.W()/2-50, dialog.H()-40, 100, 30, "Close")
+	closeBtn.SetCallback(func() {
+		dialog.Hide()
+	})
+
+	dialog.End()
+	dialog.Show()
+}
+
+// healthLine returns the browser line of the check, failed checks are red
+// and optional tools are marked.
+func healthLine(c convert.ToolCheck) string {
+	state, detail := "@C2PASS", c.Version
+	if !c.OK() {
+		state, detail = "@C1FAIL", c.Err.Error()
+	}
+	name := c.Name
+	if !c.Required {
+		name += " (optional)"
+	}
+	// tabs separate the columns
+	detail = strings.ReplaceAll(detail, "\t", " ")
+	return state + "\t" + name + "\t" + detail + "\t" + c.Path
+}
+
+// CheckInstallation checks the tools in the background and warns when a
+// tool needed by the backend is missing. fltk.Lock has to be called before.
+func (a *App) CheckInstallation() {
+	sysconfig, fs := a.sysconfig, a.projectconfig.Script
+	go func() {
+		checks := sysconfig.Check(fs)
+		missing := convert.MissingRequired(checks)
+		if len(missing) == 0 {
+			return
+		}
+		var lines []string
+		for _, c := range missing {
+			slog.Warn("installation check", "tool", c.Name, "path", c.Path, "error", c.Err)
+			lines = append(lines, c.Name+": "+c.Err.Error())
+		}
+		fltk.Awake(func() {
+			fltk.MessageBox("Installation Check", "Required tools are missing, check the system configuration:\n"+strings.Join(lines, "\n"))
+		})
+	}()
+}
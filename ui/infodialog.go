@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+	"github.com/pwiecz/go-fltk"
+)
+
+// videoInfoDialog shows the media details of an item and lets the user
+// choose the audio tracks to convert and correct the audio delay of the file.
+func videoInfoDialog(item *Item) {
+	info := item.info
+
+	// Create a modal window
+	dialog := fltk.NewWindow(600, 420, info.Name)
+	dialog.SetModal() // Set the window as modal
+	dialog.Begin()
+
+	mainBox := fltk.NewGroup(0, 0, dialog.W(), dialog.H())
+	dialog.Add(mainBox)
+
+	// Media details
+	details := fltk.NewBrowser(10, 10, 580, 180)
+	details.Add(fmt.Sprintf("File: %s", info.FullPath))
+	details.Add(fmt.Sprintf("Container: %s, %s, %s", info.Container, util.FormatSize(info.Size), util.FormatBitRate(info.BitRate)))
+	// Timecodes need the frame rate of the video
+	var rate mediatime.FrameRate
+	if v := info.FirstVideo(); v != nil {
+		rate = v.FrameRate
+	}
+	if rate.IsZero() {
+		details.Add(fmt.Sprintf("Duration: %s", mediatime.FormatDuration(info.Length())))
+	} else {
+		details.Add(fmt.Sprintf("Duration: %s, timecode %s", mediatime.FormatDuration(info.Length()),
+			mediatime.FormatTimecode(info.Length(), rate)))
+	}
+	for i := range info.Video {
+		v := &info.Video[i]
+		details.Add(fmt.Sprintf("Video #%d: %s %s, %s FPS, %s", v.Index, v.Codec,
+			util.FormatResolution(v), util.FormatFrameRate(v), util.FormatPixelFormat(v)))
+	}
+	for i := range info.Subtitles {
+		details.Add(fmt.Sprintf("Subtitle: %s", util.FormatSubtitle(&info.Subtitles[i])))
+	}
+	mainBox.Add(details)
+
+	// Audio tracks, nothing checked converts the default track
+	tracksBox := fltk.NewBox(fltk.NO_BOX, 10, 200, 400, 20, "Audio tracks to convert")
+	tracksBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	tracks := fltk.NewCheckBrowser(10, 220, 580, 100)
+	for i := range info.Audio {
+		a := &info.Audio[i]
+		tracks.Add(util.FormatAudio(a), slices.Contains(item.audioTracks, a.Index))
+	}
+	mainBox.Add(tracksBox)
+	mainBox.Add(tracks)
+
+	// Delay correction of this file, added to the delay of the project
+	delayBox := fltk.NewBox(fltk.NO_BOX, 10, 330, 140, 30, "Audio delay (ms)")
+	delayBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	delayInput := fltk.NewIntInput(150, 330, 80, 30)
+	delayInput.SetValue(strconv.Itoa(int(item.audioDelay.Milliseconds())))
+	mainBox.Add(delayBox)
+	mainBox.Add(delayInput)
+
+	// Part of the file to convert as "hh:mm:ss.mmm" or timecode "hh:mm:ss:ff",
+	// empty end converts to the end
+	trimBox := fltk.NewBox(fltk.NO_BOX, 250, 330, 60, 30, "Trim")
+	trimBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	trimStartInput := fltk.NewInput(310, 330, 120, 30)
+	trimStartInput.SetValue(mediatime.FormatDuration(item.trimStart))
+	trimEndInput := fltk.NewInput(440, 330, 120, 30)
+	if item.trimEnd > 0 {
+		trimEndInput.SetValue(mediatime.FormatDuration(item.trimEnd))
+	}
+	trimStartInput.SetTooltip("hh:mm:ss.mmm or timecode hh:mm:ss:ff")
+	trimEndInput.SetTooltip("hh:mm:ss.mmm or timecode hh:mm:ss:ff, empty converts to the end of the file")
+	mainBox.Add(trimBox)
+	mainBox.Add(trimStartInput)
+	mainBox.Add(trimEndInput)
+
+	// Bottom Buttons
+	cancelBtn := fltk.NewButton(mainBox.W()/2-110, mainBox.H()-40, 100, 30, "Cancel")
+	saveBtn := fltk.NewButton(mainBox.W()/2+10, mainBox.H()-40, 100, 30, "Save")
+	cancelBtn.SetCallback(func() {
+		dialog.Hide()
+	})
+	saveBtn.SetCallback(func() {
+		trimStart, err := mediatime.ParsePosition(trimStartInput.Value(), rate)
+		if err != nil {
+			fltk.MessageBox(info.Name, err.Error())
+			return
+		}
+		trimEnd, err := mediatime.ParsePosition(trimEndInput.Value(), rate)
+		if err != nil {
+			fltk.MessageBox(info.Name, err.Error())
+			return
+		}
+		if trimEnd > 0 && trimEnd <= trimStart {
+			fltk.MessageBox(info.Name, "trim end must be after the start")
+			return
+		}
+		item.trimStart, item.trimEnd = trimStart, trimEnd
+
+		item.audioTracks = nil
+		for i := range info.Audio {
+			// Check browser lines start at 1
+			if tracks.IsChecked(i + 1) {
+				item.audioTracks = append(item.audioTracks, info.Audio[i].Index)
+			}
+		}
+		if delay, err := strconv.Atoi(delayInput.Value()); err == nil {
+			item.audioDelay = time.Duration(delay) * time.Millisecond
+		}
+		slog.Debug("audio selection changed", "file", info.Name, "tracks", item.audioTracks, "delay", item.audioDelay)
+		dialog.Hide()
+	})
+	mainBox.Add(cancelBtn)
+	mainBox.Add(saveBtn)
+
+	// Finalize the window and display it
+	dialog.End()
+	dialog.Show()
+}
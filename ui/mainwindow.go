@@ -1,11 +1,16 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/archeopternix/gofltk-videoconverter/convert"
 	"github.com/archeopternix/gofltk-videoconverter/util"
 	"github.com/pwiecz/go-fltk"
 )
@@ -22,7 +27,14 @@ import (
 //	Scroll     – (Nicht verwendet) Scroll-Widget, ggf. für Listenanzeige vorgesehen.
 //	progress   – Fortschrittsbalken zur Anzeige des aktuellen Status (0–100%).
 //	lister     – Benutzerdefiniertes Scroll-Widget zur Anzeige und Verwaltung von Dateieinträgen.
+//	table      – Tabellenansicht der Dateieinträge mit sortierbaren Spalten.
 //	workDir    – Aktuelles Arbeitsverzeichnis für Dateioperationen.
+//	presets    – Eingebaute und benutzerdefinierte Encoder-Voreinstellungen.
+//	cancel     – Bricht die laufende Konvertierung ab, nil wenn keine läuft.
+//	previews   – Geöffnete Vorschaufenster, die bei Änderungen neu gerendert werden.
+//	watch      – Überwachte Ordner und Archivordner des Watch-Modus.
+//	watchCancel – Beendet die Überwachung der Ordner, nil wenn keine läuft.
+//	watchQueue – Neue Dateien der überwachten Ordner, die auf die Konvertierung warten.
 type App struct {
 	win           *fltk.Window   // Hauptfenster
 	MenuBar       *fltk.MenuBar  // Menüleiste
@@ -30,9 +42,16 @@ type App struct {
 	Scroll        *fltk.Scroll   // (Optional) Scroll-Widget
 	progress      *fltk.Progress // Fortschrittsanzeige
 	lister        *Scroll        // Benutzerdefinierte Liste für Dateien
+	table         *Table         // Tabellenansicht der Dateien
 	workDir       string         // Arbeitsverzeichnis
 	sysconfig     SystemConfig
 	projectconfig ProjectConfig
+	presets       *convert.Presets        // Encoder-Voreinstellungen
+	cancel        context.CancelFunc      // Bricht die laufende Konvertierung ab
+	previews      map[*previewWindow]bool // Geöffnete Vorschaufenster
+	watch         WatchConfig             // Überwachte Ordner
+	watchCancel   context.CancelFunc      // Beendet die Überwachung der Ordner
+	watchQueue    []*Item                 // Wartende Dateien der überwachten Ordner
 }
 
 func NewApp(window *fltk.Window) *App {
@@ -46,11 +65,26 @@ func NewApp(window *fltk.Window) *App {
 		workDir:       wd,
 		sysconfig:     NewSystemConfig(".", "."),
 		projectconfig: NewProjectConfig(),
+		previews:      map[*previewWindow]bool{},
+		watch:         NewWatchConfig(),
 	}
+	util.SetFFprobePath(app.sysconfig.FFprobePath)
+	app.projectconfig.OnSave(app.refreshPreviews)
+	app.loadPresets()
 	app.initMainWindow()
 	return app
 }
 
+// loadPresets loads the built-in and user defined encoder presets. Invalid
+// preset files are logged and skipped.
+func (a *App) loadPresets() {
+	presets, err := convert.LoadPresets(a.sysconfig.PresetPath)
+	if err != nil {
+		slog.Error("load presets", "dir", a.sysconfig.PresetPath, "error", err)
+	}
+	a.presets = presets
+}
+
 func (a *App) Exit() {
 	a.win.Hide()
 }
@@ -93,9 +127,6 @@ func (a *App) initMainWindow() {
 	a.ButtonMenu.Fixed(openFileBtn, 80) // Fix width to 170 px
 
 	openFolderBtn := fltk.NewButton(0, 0, 80, 70, "Open Folder")
-	openFolderBtn.SetCallback(func() {
-		fmt.Println("OpenFolderBtn")
-	})
 	openFolderBtn.SetAlign(fltk.ALIGN_IMAGE_OVER_TEXT)
 	imgFolder, err := fltk.NewPngImageLoad("img/folder-open.png")
 	if err != nil {
@@ -104,6 +135,7 @@ func (a *App) initMainWindow() {
 	openFolderBtn.SetImage(imgFolder)
 	openFolderBtn.SetCallback(func() {
 		fmt.Println("OpenFolder")
+		a.openFolder()
 	})
 	openFolderBtn.SetLabelSize(labelSize)
 	a.ButtonMenu.Fixed(openFolderBtn, 80)
@@ -121,9 +153,28 @@ func (a *App) initMainWindow() {
 	RunBtn.SetImage(imgRun)
 	RunBtn.SetCallback(func() {
 		fmt.Println("Run")
+		a.convertFiles()
 	})
 	a.ButtonMenu.Fixed(RunBtn, 80) // Fix width to 170 px
 
+	WatchBtn := fltk.NewToggleButton(0, 0, 80, 70, "Watch")
+	WatchBtn.SetAlign(fltk.ALIGN_IMAGE_OVER_TEXT)
+	imgWatch, err := fltk.NewPngImageLoad("img/folder-videos.png")
+	if err != nil {
+		slog.Error("button watch", "image:", err)
+	}
+	WatchBtn.SetLabelSize(labelSize)
+	WatchBtn.SetImage(imgWatch)
+	WatchBtn.SetTooltip("Convert new files of watched folders")
+	WatchBtn.SetCallback(func() {
+		if WatchBtn.Value() {
+			a.watchDialog(WatchBtn)
+		} else {
+			a.stopWatch()
+		}
+	})
+	a.ButtonMenu.Fixed(WatchBtn, 80)
+
 	sep2 := fltk.NewBox(fltk.NO_BOX, 0, 0, 20, 70, "")
 	a.ButtonMenu.Fixed(sep2, 20)
 
@@ -137,7 +188,7 @@ func (a *App) initMainWindow() {
 	SettingsBtn.SetImage(imgSettings)
 	SettingsBtn.SetCallback(func() {
 		fmt.Println("Settings")
-		a.sysconfig.Dialog()
+		a.sysconfig.Dialog(a.projectconfig.Script)
 	})
 	a.ButtonMenu.Fixed(SettingsBtn, 80) // Fix width to 170 px
 
@@ -151,18 +202,65 @@ func (a *App) initMainWindow() {
 	ConfigBtn.SetImage(imgConfig)
 	ConfigBtn.SetCallback(func() {
 		fmt.Println("Config")
-		a.projectconfig.Dialog()
+		// Reload to show presets added since the last run
+		a.loadPresets()
+		a.projectconfig.Dialog(a.presets)
 	})
 	a.ButtonMenu.Fixed(ConfigBtn, 80) // Fix width to 170 px
 
+	FilterBtn := fltk.NewButton(0, 0, 80, 70, "Filters")
+	FilterBtn.SetAlign(fltk.ALIGN_IMAGE_OVER_TEXT)
+	imgFilter, err := fltk.NewPngImageLoad("img/system-run.png")
+	if err != nil {
+		slog.Error("button filters", "image:", err)
+	}
+	FilterBtn.SetLabelSize(labelSize)
+	FilterBtn.SetImage(imgFilter)
+	FilterBtn.SetCallback(func() {
+		a.projectconfig.FilterDialog(filepath.Join(a.sysconfig.PresetPath, "filters"))
+	})
+	a.ButtonMenu.Fixed(FilterBtn, 80)
+
 	bx := fltk.NewBox(fltk.NO_BOX, 0, 0, 1, 1, "")
 	a.ButtonMenu.Add(bx)
 	a.ButtonMenu.End()
 
 	mainContent := fltk.NewFlex(0, 85, a.win.W(), a.win.H()-80-25)
 	mainContent.Begin()
-	a.lister = NewScroll(0, 0, mainContent.W(), mainContent.H())
-	// ... add widgets to mainContent ...
+
+	// Filter box and toggle between row and table view
+	listBar := fltk.NewFlex(0, 0, mainContent.W(), 25)
+	listBar.SetType(fltk.ROW)
+	listBar.SetGap(5)
+	listBar.Begin()
+	filterLbl := fltk.NewBox(fltk.NO_BOX, 0, 0, 40, 25, "Filter")
+	filterLbl.SetLabelSize(labelSize)
+	listBar.Fixed(filterLbl, 40)
+	filterInput := fltk.NewInput(0, 0, 200, 25)
+	filterInput.SetTooltip("Show only files containing this text")
+	filterInput.SetCallbackCondition(fltk.WhenChanged)
+	filterInput.SetCallback(func() {
+		a.lister.SetFilter(filterInput.Value())
+	})
+	viewBtn := fltk.NewToggleButton(0, 0, 90, 25, "Table view")
+	viewBtn.SetLabelSize(labelSize)
+	viewBtn.SetCallback(func() {
+		a.showTable(viewBtn.Value())
+	})
+	listBar.Fixed(viewBtn, 90)
+	listBar.End()
+	mainContent.Fixed(listBar, 25)
+
+	listGroup := fltk.NewGroup(0, 0, mainContent.W(), mainContent.H()-25)
+	listGroup.Begin()
+	a.lister = NewScroll(0, 0, listGroup.W(), listGroup.H())
+	a.table = NewTable(0, 0, listGroup.W(), listGroup.H(), a.lister)
+	a.table.Hide()
+	listGroup.End()
+	a.lister.OnChange(a.table.Refresh)
+	a.lister.OnDrop(a.addFiles)
+	a.lister.OnPreview(a.openPreview)
+
 	mainContent.End()
 
 	a.win.Resizable(mainContent)
@@ -176,6 +274,21 @@ func (a *App) initMainWindow() {
 	a.progress.SetValue(50)
 
 	a.win.End()
+
+	// Accept files and folders dropped anywhere onto the main window
+	a.win.SetEventHandler(dropHandler(a.addFiles))
+}
+
+// showTable toggles between the compact row view and the table view.
+func (a *App) showTable(table bool) {
+	if table {
+		a.lister.fltkScroll.Hide()
+		a.table.Show()
+		return
+	}
+	a.table.Hide()
+	a.lister.fltkScroll.Show()
+	a.lister.Refresh()
 }
 
 /*
@@ -261,16 +374,17 @@ func MainWin(window *fltk.Window) {
 	win.End()
 }
 
+*/
 
-// openDirectory prompts the user to select a directory, processes its video files,
+// openFile prompts the user to select one or more video files, processes them,
 // and adds them to the scrollable list.
-func openDirectory() {
-	// Create a new directory chooser dialog
+func (a *App) openFile() {
+	// Create a new file chooser dialog for video files
 	chooser := fltk.NewFileChooser(
-		workingDir,                 // Default directory
-		"*.*",                      // File filter (all files)
-		fltk.FileChooser_DIRECTORY, // Mode: Open directory
-		"Select Directory",         // Dialog title
+		a.workDir,                          // Default directory
+		"*.{mp4,mpeg,avi,vob,mpg,mov,m2t}", // Video file filter
+		fltk.FileChooser_MULTI,             // Mode: Select multiple files
+		"Select File",                      // Dialog title
 	)
 	chooser.Show()
 
@@ -279,49 +393,23 @@ func openDirectory() {
 		fltk.Wait()
 	}
 
-	// Handle case where no directory was selected
+	// Handle case where no files are selected
 	if len(chooser.Selection()) == 0 {
-		slog.Info("open directory", "no files selected")
+		slog.Info("open file", "msg", "no files selected")
 		return
 	}
 
-	// Set up a new workflow to process the directory contents
-	wf := filter.NewWorkflow()
-	selection := chooser.Selection()
-	workingDir = selection[0]
-
-	wf.Add(filter.NewDirectoryReader(selection[0])) // Add directory reader step
-	wf.Add(filter.NewVideoFileFilter())             // Add video file filter step
-
-	list, err := wf.Process(nil) // Process directory
-	if err != nil {
-		slog.Error("open directory", "message", err)
-		return
-	}
-
-	// If no video files found, log and return
-	if len(list) == 0 {
-		slog.Info("open directory", "no video files selected")
-		return
-	}
-
-	// Add each processed video file to the scrollable list
-	for _, item := range list {
-		lister.AddRow(util.GetInfoFromFileName(item))
-	}
+	a.addFiles(chooser.Selection())
 }
 
-*/
-
-// openFile prompts the user to select one or more video files, processes them,
-// and adds them to the scrollable list.
-func (a *App) openFile() {
-	// Create a new file chooser dialog for video files
+// openFolder prompts the user to select a directory and adds all video files
+// of the directory and its subdirectories to the scrollable list.
+func (a *App) openFolder() {
 	chooser := fltk.NewFileChooser(
-		a.workDir,                          // Default directory
-		"*.{mp4,mpeg,avi,vob,mpg,mov,m2t}", // Video file filter
-		fltk.FileChooser_MULTI,             // Mode: Select multiple files
-		"Select File",                      // Dialog title
+		a.workDir,                  // Default directory
+		"*.*",                      // File filter (all files)
+		fltk.FileChooser_DIRECTORY, // Mode: Open directory
+		"Select Directory",         // Dialog title
 	)
 	chooser.Show()
 
@@ -330,45 +418,245 @@ func (a *App) openFile() {
 		fltk.Wait()
 	}
 
-	// Handle case where no files are selected
+	// Handle case where no directory was selected
 	if len(chooser.Selection()) == 0 {
-		slog.Info("open directory", "no files selected")
+		slog.Info("open directory", "msg", "no directory selected")
 		return
 	}
 
-	var videofiles []string
+	a.addFiles(chooser.Selection())
+}
 
-	for _, file := range chooser.Selection() {
-		if util.IsVideo(file) {
-			videofiles = append(videofiles, filepath.ToSlash(file))
-		}
-	}
+// addFiles validates files with util.IsVideo, imports folders recursively
+// and adds all video files to the scrollable list. Used by the file and
+// folder dialogs and for files dropped onto the window. Duplicates by
+// content are skipped on request if the detection is enabled.
+func (a *App) addFiles(paths []string) {
+	videofiles := util.CollectVideoFiles(paths)
 
 	// If no valid video files found, log and return
 	if len(videofiles) == 0 {
-		slog.Info("open files", "no video files selected")
+		slog.Info("open files", "msg", "no video files selected")
 		return
 	}
 
 	// Update working directory to the location of the first file
 	a.workDir, _ = filepath.Split(videofiles[0])
+	if a.sysconfig.DetectDuplicates {
+		videofiles = a.skipDuplicates(videofiles)
+	}
 	// Add each processed video file to the scrollable list
 	for _, item := range videofiles {
 		a.lister.AddRow(util.GetInfoFromFileName(item))
 	}
 }
 
-/*
-// generateFiles collects all file paths from the scroll list
-// and initializes a new workflow for further processing (e.g., batch processing).
-func generateFiles() {
-	// 1. Collect all file paths from the scroll list
-	files := lister.GetFiles()
-	slog.Debug("Files to process", "files", files)
+// convertFiles converts all selected files, or all files when nothing is
+// selected, with the configured backend. The conversion runs in the
+// background, pressing Run again stops it.
+func (a *App) convertFiles() {
+	if a.cancel != nil {
+		slog.Info("convert files", "msg", "stopped by user")
+		a.cancel()
+		return
+	}
 
-	// 2. Create a new workflow using filter/workflow package
-	//	wf := workflow.NewWorkflow() // Replace with correct constructor if needed
+	items := a.lister.SelectedItems()
+	if len(items) == 0 {
+		items = a.lister.Items()
+	}
+	if len(items) == 0 {
+		slog.Info("convert files", "msg", "no files in list")
+		return
+	}
 
-	// [Further processing steps would go here]
+	if err := a.convertItems(items); err != nil {
+		fltk.MessageBox("Convert", err.Error())
+	}
+}
+
+// convertItems starts the conversion of the items in the background. All
+// jobs are checked before, the batch is not started if one check fails.
+func (a *App) convertItems(items []*Item) error {
+	preset, ok := a.presets.Get(a.projectconfig.Encoder)
+	if !ok {
+		slog.Error("convert files", "msg", "unknown encoder", "encoder", a.projectconfig.Encoder)
+		return fmt.Errorf("unknown encoder %q", a.projectconfig.Encoder)
+	}
+
+	backend := a.sysconfig.NewBackend()
+	ffmpeg := a.sysconfig.FFmpegPath
+	versions := toolVersions(a.sysconfig, a.projectconfig.Script)
+	skip := a.projectconfig.Skip
+	jobs := make([]*convert.Job, len(items))
+	for i, item := range items {
+		jobs[i] = a.projectconfig.NewJob(item, preset)
+		// Check all jobs before the batch starts, missing tools or plugins
+		// would fail every job. The target size switches to two passes,
+		// which not every backend supports.
+		err := jobs[i].ApplyTargetSize()
+		if err == nil {
+			err = backend.Check(jobs[i])
+		}
+		if err != nil {
+			slog.Error("convert files", "file", item.info.Name, "backend", backend.Name(), "error", err)
+			item.SetStatus("error: " + err.Error())
+			a.table.Refresh()
+			return fmt.Errorf("%s: %w", item.info.Name, err)
+		}
+	}
+	// The goroutine reads the jobs and the archive folders, the items are
+	// changed on the UI thread only
+	archiveDirs := make([]string, len(items))
+	for i, item := range items {
+		archiveDirs[i] = item.archiveDir
+		item.SetStatus("queued")
+	}
+	a.table.Refresh()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	go func() {
+		defer fltk.Awake(func() {
+			cancel()
+			a.cancel = nil
+			a.SetProgress(100, "Finished")
+			a.batchFinished()
+		})
+		defer a.exportMetrics(jobs)
+
+		for i, job := range jobs {
+			item, name := items[i], job.Source.Name
+			if ctx.Err() != nil {
+				a.setStatus(item, "cancelled")
+				continue
+			}
+			a.setStatus(item, "running")
+
+			status, err := convertJob(ctx, backend, ffmpeg, job, skip, versions, func(p convert.Progress) {
+				a.reportProgress(i, len(jobs), name, p)
+			})
+			switch {
+			case ctx.Err() != nil:
+				a.setStatus(item, "cancelled")
+			case err != nil:
+				slog.Error("convert files", "file", name, "backend", backend.Name(), "error", err)
+				a.setStatus(item, "error: "+err.Error())
+			default:
+				if job.Scores != nil {
+					scores := job.Scores
+					slog.Info("convert files", "file", name, "metrics", scores.String())
+					fltk.Awake(func() {
+						item.SetMetrics(scores)
+						a.lister.Refresh()
+					})
+				}
+				if archiveDirs[i] != "" {
+					var moved string
+					status, moved = archiveSource(job.Source.FullPath, archiveDirs[i], status)
+					fltk.Awake(func() { a.lister.MoveItem(item, moved) })
+				}
+				a.setStatus(item, status)
+			}
+		}
+	}()
+	return nil
+}
+
+// convertJob converts the job with the backend and returns the status of
+// the converted file with the size of the output. The conversion is
+// recorded in the manifest of the output directory with the tool versions.
+// With skip jobs up to date according to the manifest are not converted,
+// the tool versions are compared as well.
+func convertJob(ctx context.Context, backend convert.Backend, ffmpeg string, job *convert.Job, skip bool,
+	versions func() map[string]string, report func(convert.Progress)) (string, error) {
+	name := job.Source.Name
+	// The settings are hashed before the conversion changes the job
+	entry, err := convert.NewManifestEntry(job, backend.Name(), versions())
+	if err != nil {
+		slog.Error("convert files", "file", name, "msg", "not recorded in manifest", "error", err)
+	} else if skip {
+		manifest, err := convert.LoadManifest(job.OutputDir)
+		if err != nil {
+			slog.Error("convert files", "file", name, "error", err)
+		} else if manifest.UpToDate(job, entry) {
+			slog.Info("convert files", "file", name, "output", job.OutputPath(), "msg", "up to date, skipped")
+			return "skipped, up to date", nil
+		}
+	}
+
+	if job.Chapter.Mode == convert.ChaptersScenes {
+		cuts, err := convert.DetectSceneCuts(ctx, ffmpeg, job)
+		if err != nil {
+			slog.Error("convert files", "file", name, "error", err)
+		}
+		job.SceneCuts = cuts
+	}
+
+	if err := backend.Run(ctx, job, report); err != nil {
+		return "", err
+	}
+	if entry.SourceHash != "" {
+		if err := convert.Record(job, entry); err != nil {
+			slog.Error("convert files", "file", name, "msg", "not recorded in manifest", "error", err)
+		}
+	}
+	size, err := convert.SizeReport(job)
+	if err != nil {
+		slog.Error("convert files", "file", name, "error", err)
+		return "done", nil
+	}
+	slog.Info("convert files", "file", name, "output", job.OutputPath(), "size", size)
+	return "done, " + size, nil
+}
+
+// toolVersions returns a function querying the versions of the tools for
+// the manifest once, on the first call.
+func toolVersions(s SystemConfig, fs convert.Frameserver) func() map[string]string {
+	tools, backend := s.Tools(), s.Backend
+	return sync.OnceValue(func() map[string]string {
+		return convert.ToolVersions(context.Background(), tools, backend, fs)
+	})
+}
+
+// refreshPreviews renders the open preview windows with the changed settings.
+func (a *App) refreshPreviews() {
+	for p := range a.previews {
+		p.refresh()
+	}
+}
+
+// exportMetrics writes the quality metrics of the batch as CSV file into
+// the output directory.
+func (a *App) exportMetrics(jobs []*convert.Job) {
+	if !slices.ContainsFunc(jobs, func(j *convert.Job) bool { return j.Scores != nil }) {
+		return
+	}
+	path := filepath.Join(jobs[0].OutputDir, "metrics_"+time.Now().Format("20060102-150405")+".csv")
+	if err := convert.WriteMetricsCSV(path, jobs); err != nil {
+		slog.Error("export metrics", "file", path, "error", err)
+		return
+	}
+	slog.Info("export metrics", "file", path)
+}
+
+// setStatus sets the status of the item from a background goroutine.
+func (a *App) setStatus(item *Item, status string) {
+	fltk.Awake(func() {
+		item.SetStatus(status)
+		a.table.Refresh()
+	})
+}
+
+// reportProgress shows the progress of job i of n from a background goroutine.
+func (a *App) reportProgress(i, n int, name string, p convert.Progress) {
+	percent := 0.0
+	text := fmt.Sprintf("%s: %s", name, p.Stage)
+	if p.Percent >= 0 {
+		percent = p.Percent
+		text = fmt.Sprintf("%s %.0f%%", text, p.Percent)
+	}
+	fltk.Awake(func() {
+		a.SetProgress(int((float64(i)*100+percent)/float64(n)), text)
+	})
 }
-*/
@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/pwiecz/go-fltk"
+)
+
+// previewWindow shows a frame of the source next to the frame filtered with
+// the settings of the project, side by side or split by a slider. Frames
+// are rendered in the background into a temporary directory.
+type previewWindow struct {
+	app      *App
+	item     *Item
+	win      *fltk.Window
+	view     *fltk.Box      // Shows the composed frame
+	timeline *fltk.Slider   // Position in the source in seconds
+	split    *fltk.Slider   // Position of the split 0..1
+	timeBox  *fltk.Box      // Timecode of the shown frame
+	shown    *fltk.RgbImage // Image currently set on the view
+	dir      string         // Temporary directory for scripts and frames
+
+	sideBySide    bool        // Show both frames next to each other
+	before, after image.Image // Last rendered frames
+	rendering     bool        // A render is running
+	pending       bool        // Render again when the running render is done
+	cancel        context.CancelFunc
+}
+
+// openPreview opens the preview window of the item.
+func (a *App) openPreview(item *Item) {
+	dir, err := os.MkdirTemp("", "preview")
+	if err != nil {
+		slog.Error("preview", "file", item.info.Name, "error", err)
+		return
+	}
+	p := &previewWindow{app: a, item: item, dir: dir}
+	p.build()
+	a.previews[p] = true
+	p.refresh()
+}
+
+// build creates the widgets of the window.
+func (p *previewWindow) build() {
+	info := p.item.info
+	p.win = fltk.NewWindow(800, 560, "Preview: "+info.Name)
+	p.win.Begin()
+
+	p.view = fltk.NewBox(fltk.DOWN_BOX, 10, 10, 780, 440, "")
+
+	// Timeline in seconds, stepping by one frame
+	p.timeline = fltk.NewSlider(10, 460, 780, 20)
+	p.timeline.SetType(fltk.HOR_NICE_SLIDER)
+	p.timeline.SetMinimum(0)
+	p.timeline.SetMaximum(info.Length().Seconds())
+	p.timeline.SetStep(p.frameDuration().Seconds())
+	p.timeline.SetCallbackCondition(fltk.WhenChanged)
+	p.timeline.SetCallback(p.refresh)
+
+	prevBtn := fltk.NewButton(10, 490, 40, 30, "@<")
+	prevBtn.SetTooltip("Previous frame")
+	prevBtn.SetCallback(func() { p.step(-1) })
+	nextBtn := fltk.NewButton(55, 490, 40, 30, "@>")
+	nextBtn.SetTooltip("Next frame")
+	nextBtn.SetCallback(func() { p.step(1) })
+
+	p.timeBox = fltk.NewBox(fltk.NO_BOX, 100, 490, 140, 30, "")
+	p.timeBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+
+	// Comparison mode, the split slider moves the border between the frames
+	modeChoice := fltk.NewChoice(250, 490, 140, 30, "")
+	modeChoice.Add("Split view", func() {
+		p.sideBySide = false
+		p.split.Activate()
+		p.show()
+	})
+	modeChoice.Add("Side by side", func() {
+		p.sideBySide = true
+		p.split.Deactivate()
+		p.show()
+	})
+	modeChoice.SetValue(0)
+
+	p.split = fltk.NewSlider(400, 495, 280, 20)
+	p.split.SetType(fltk.HOR_NICE_SLIDER)
+	p.split.SetMinimum(0)
+	p.split.SetMaximum(1)
+	p.split.SetValue(0.5)
+	p.split.SetCallbackCondition(fltk.WhenChanged)
+	p.split.SetCallback(p.show)
+
+	closeBtn := fltk.NewButton(690, 490, 100, 30, "Close")
+	closeBtn.SetCallback(p.close)
+	p.win.SetCallback(p.close)
+
+	p.win.End()
+	p.win.Show()
+}
+
+// frameDuration returns the duration of a source frame, 40ms without video.
+func (p *previewWindow) frameDuration() time.Duration {
+	if v := p.item.info.FirstVideo(); v != nil && !v.FrameRate.IsZero() {
+		return v.FrameRate.FrameDuration()
+	}
+	return 40 * time.Millisecond
+}
+
+// position returns the time of the timeline.
+func (p *previewWindow) position() time.Duration {
+	return time.Duration(p.timeline.Value() * float64(time.Second))
+}
+
+// step moves the timeline by n frames and renders the frame.
+func (p *previewWindow) step(n int) {
+	value := p.timeline.Value() + float64(n)*p.frameDuration().Seconds()
+	p.timeline.SetValue(max(0, min(value, p.item.info.Length().Seconds())))
+	p.refresh()
+}
+
+// refresh renders the frame at the timeline position with the current
+// project settings. A refresh during a render is queued, only the last
+// one is rendered.
+func (p *previewWindow) refresh() {
+	if p.rendering {
+		p.pending = true
+		return
+	}
+	p.rendering, p.pending = true, false
+
+	a := p.app
+	at := p.position()
+	p.timeBox.SetLabel(mediatime.FormatDuration(at))
+	preset, _ := a.presets.Get(a.projectconfig.Encoder)
+	job := convert.PreviewJob(a.projectconfig.NewJob(p.item, preset), p.dir)
+	tools, backend := a.sysconfig.Tools(), a.sysconfig.Backend
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go func() {
+		err := convert.RenderPreview(ctx, tools, backend, job, at)
+		var before, after image.Image
+		if err == nil {
+			beforePath, afterPath := job.PreviewPaths()
+			if before, err = loadPNG(beforePath); err == nil {
+				after, err = loadPNG(afterPath)
+			}
+		}
+		fltk.Awake(func() {
+			p.rendering = false
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("preview", "file", p.item.info.Name, "error", err)
+				p.timeBox.SetLabel(mediatime.FormatDuration(at) + " failed")
+			} else {
+				p.before, p.after = before, after
+				p.show()
+			}
+			if p.pending {
+				p.refresh()
+			}
+		})
+	}()
+}
+
+// show composes the rendered frames and scales them into the view.
+func (p *previewWindow) show() {
+	if p.before == nil || p.after == nil {
+		return
+	}
+	img, err := fltk.NewRgbImageFromImage(composePreview(p.before, p.after, p.sideBySide, p.split.Value()))
+	if err != nil {
+		slog.Error("preview", "file", p.item.info.Name, "error", err)
+		return
+	}
+	img.Scale(p.view.W()-4, p.view.H()-4, true, true)
+	p.view.SetImage(img)
+	if p.shown != nil {
+		p.shown.Destroy()
+	}
+	p.shown = img
+	p.view.Redraw()
+}
+
+// close stops rendering, removes the temporary files and hides the window.
+func (p *previewWindow) close() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	delete(p.app.previews, p)
+	if err := os.RemoveAll(p.dir); err != nil {
+		slog.Error("preview", "dir", p.dir, "error", err)
+	}
+	p.win.Hide()
+}
+
+// loadPNG reads a PNG image.
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return img, nil
+}
+
+// composePreview returns the frames side by side, or the left part of the
+// source frame and the right part of the filtered frame split at the
+// position 0..1. The source frame is scaled to the size of the filtered
+// frame for the split view.
+func composePreview(before, after image.Image, sideBySide bool, split float64) *image.RGBA {
+	b := after.Bounds()
+	if sideBySide {
+		bb := before.Bounds()
+		img := image.NewRGBA(image.Rect(0, 0, bb.Dx()+b.Dx(), max(bb.Dy(), b.Dy())))
+		copyImage(img, before, bb.Dx(), bb.Dy())
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				img.Set(bb.Dx()+x, y, after.At(b.Min.X+x, b.Min.Y+y))
+			}
+		}
+		return img
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	border := int(split * float64(b.Dx()))
+	copyImage(img, before, b.Dx(), b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := border; x < b.Dx(); x++ {
+			img.Set(x, y, after.At(b.Min.X+x, b.Min.Y+y))
+		}
+		if border < b.Dx() {
+			img.Set(border, y, color.White)
+		}
+	}
+	return img
+}
+
+// copyImage draws the source scaled to w x h into the top left corner of
+// the image, nearest neighbour is good enough for a preview.
+func copyImage(img *image.RGBA, src image.Image, w, h int) {
+	sb := src.Bounds()
+	for y := 0; y < h; y++ {
+		sy := sb.Min.Y + y*sb.Dy()/h
+		for x := 0; x < w; x++ {
+			img.Set(x, y, src.At(sb.Min.X+x*sb.Dx()/w, sy))
+		}
+	}
+}
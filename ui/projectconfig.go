@@ -1,8 +1,12 @@
 package ui
 
 import (
+	"fmt"
 	"log/slog"
+	"strconv"
+	"time"
 
+	"github.com/archeopternix/gofltk-videoconverter/convert"
 	"github.com/pwiecz/go-fltk"
 )
 
@@ -11,6 +15,25 @@ type ProjectConfig struct {
 	WorkDir   string // for intermediate files
 	Encoder   string
 	Cleanup   bool
+	Audio     convert.AudioSettings    // audio codec, downmix, resampling and delay
+	Subtitles convert.SubtitleSettings // subtitle and closed caption passthrough
+	Chapters  convert.ChapterSettings  // source or generated chapters
+	Script    convert.Frameserver      // AviSynth+ or VapourSynth
+	Width     int                      // output width, 0 keeps the source width
+	Height    int                      // output height, 0 keeps the source height
+	TargetMB  int                      // output size in MB for two-pass encoding, 0 for the rate control of the encoder
+	TwoStage  convert.LosslessCodec    // codec of the lossless intermediate, empty encodes in one stage
+	Verify    convert.VerifyMode       // check of the output after the conversion
+	Metrics   bool                     // compute PSNR, SSIM and VMAF of the outputs
+	Filters   convert.FilterChain      // video filters, edited in the filter dialog
+	Skip      bool                     // skip files converted before with the same source and settings
+
+	onSave func() // called after the dialog saved the changes
+}
+
+// OnSave sets the function called after the dialog saved the changes.
+func (p *ProjectConfig) OnSave(f func()) {
+	p.onSave = f
 }
 
 func NewProjectConfig() ProjectConfig {
@@ -19,6 +42,36 @@ func NewProjectConfig() ProjectConfig {
 		WorkDir:   ".",
 		Encoder:   "MP4 (x264 8bit)",
 		Cleanup:   false,
+		Audio:     convert.NewAudioSettings(),
+		Chapters:  convert.NewChapterSettings(),
+	}
+}
+
+// NewJob returns the conversion job of the item with the settings of the
+// project, encoded with the preset.
+func (p *ProjectConfig) NewJob(item *Item, preset convert.Preset) *convert.Job {
+	return &convert.Job{
+		Source:       item.info,
+		Preset:       preset,
+		Frameserver:  p.Script,
+		Container:    preset.Container,
+		Audio:        p.Audio.ForContainer(preset.Container),
+		AudioTracks:  item.audioTracks,
+		AudioDelay:   item.audioDelay,
+		Subtitles:    p.Subtitles,
+		Chapter:      p.Chapters,
+		TrimStart:    item.trimStart,
+		TrimEnd:      item.trimEnd,
+		Width:        p.Width,
+		Height:       p.Height,
+		Filters:      p.Filters.Clone(),
+		TargetSize:   int64(p.TargetMB) * 1000 * 1000,
+		Cleanup:      p.Cleanup,
+		Intermediate: p.TwoStage,
+		Verify:       p.Verify,
+		Analyze:      p.Metrics,
+		WorkDir:      p.WorkDir,
+		OutputDir:    p.OutputDir,
 	}
 }
 
@@ -26,9 +79,10 @@ func NewProjectConfig() ProjectConfig {
 
 // vdubConfigDialog creates and displays a modal dialog window
 // to edit path to VirtualDub2, working and output directory and the used encoder.
-func (p *ProjectConfig) Dialog() {
+// The encoder dropdown lists all presets of the registry.
+func (p *ProjectConfig) Dialog(presets *convert.Presets) {
 	// Create a modal window
-	dialog := fltk.NewWindow(600, 260, "Project Configuration")
+	dialog := fltk.NewWindow(600, 500, "Project Configuration")
 	dialog.SetModal() // Set the window as modal
 	dialog.Begin()
 
@@ -37,6 +91,17 @@ func (p *ProjectConfig) Dialog() {
 		WorkDir:   p.WorkDir,
 		Encoder:   p.Encoder,
 		Cleanup:   p.Cleanup,
+		Audio:     p.Audio,
+		Subtitles: p.Subtitles,
+		Chapters:  p.Chapters,
+		Script:    p.Script,
+		Width:     p.Width,
+		Height:    p.Height,
+		TargetMB:  p.TargetMB,
+		TwoStage:  p.TwoStage,
+		Verify:    p.Verify,
+		Metrics:   p.Metrics,
+		Skip:      p.Skip,
 	}
 
 	// Create a vertical box for layout
@@ -105,21 +170,228 @@ func (p *ProjectConfig) Dialog() {
 	encoderBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
 
 	encoderChoice := fltk.NewChoice(150, 90, 200, 30, "")
-	encoderChoice.Add("MP4 (x264 8bit)", func() {
-		cfg.Encoder = "MP4 (x264 8bit)"
-	})
-	encoderChoice.Add("MP4 (x264 10bit)", func() {
-		cfg.Encoder = "MP4 (x264 10bit)"
-	})
-	encoderChoice.Add("Huffyuv (lossless)", func() {
-		cfg.Encoder = "Huffyuv (lossless)"
-	})
-	encoderChoice.Add("MP4 (x265 HEVC)", func() {
-		cfg.Encoder = "MP4 (x265 HEVC)"
-	})
+
+	// Audio codec Dropdown, only codecs supported by the container of the
+	// encoder are offered
+	codecBox := fltk.NewBox(fltk.NO_BOX, 10, 170, 120, 30, "Audio codec")
+	codecBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	codecChoice := fltk.NewChoice(150, 170, 200, 30, "")
+	fillCodecs := func() {
+		preset, _ := presets.Get(cfg.Encoder)
+		container := preset.Container
+		cfg.Audio = cfg.Audio.ForContainer(container)
+		codecChoice.Clear()
+		for _, codec := range convert.AudioCodecsFor(container) {
+			codecChoice.Add(string(codec), func() {
+				cfg.Audio.Codec = codec
+			})
+		}
+		codecChoice.SetValue(codecChoice.FindIndex(string(cfg.Audio.Codec)))
+	}
+
+	for _, encoder := range presets.Names() {
+		encoderChoice.Add(encoder, func() {
+			cfg.Encoder = encoder
+			fillCodecs()
+		})
+	}
 	index := encoderChoice.FindIndex(cfg.Encoder)
 	encoderChoice.SetValue(index)
 	mainBox.Add(encoderChoice)
+	fillCodecs()
+
+	// Audio bit rate for lossy codecs
+	bitrateBox := fltk.NewBox(fltk.NO_BOX, 10, 210, 120, 30, "Audio kbit/s")
+	bitrateBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	bitrateSpinner := fltk.NewSpinner(150, 210, 100, 30, "")
+	bitrateSpinner.SetType(fltk.SPINNER_INT_INPUT)
+	bitrateSpinner.SetMinimum(32)
+	bitrateSpinner.SetMaximum(512)
+	bitrateSpinner.SetStep(32)
+	bitrateSpinner.SetValue(float64(cfg.Audio.Bitrate))
+
+	// Downmix and resampling
+	downmixBox := fltk.NewBox(fltk.NO_BOX, 10, 250, 120, 30, "Audio channels")
+	downmixBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	downmixChoice := fltk.NewChoice(150, 250, 200, 30, "")
+	for i, d := range []struct {
+		label   string
+		downmix convert.Downmix
+	}{
+		{"Keep channels", convert.DownmixNone},
+		{"Downmix to stereo", convert.DownmixStereo},
+		{"Downmix to mono", convert.DownmixMono},
+	} {
+		downmixChoice.Add(d.label, func() {
+			cfg.Audio.Downmix = d.downmix
+		})
+		if d.downmix == cfg.Audio.Downmix {
+			downmixChoice.SetValue(i)
+		}
+	}
+
+	rateBox := fltk.NewBox(fltk.NO_BOX, 10, 290, 120, 30, "Sample rate")
+	rateBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	rateChoice := fltk.NewChoice(150, 290, 200, 30, "")
+	for i, rate := range []int{0, 44100, 48000} {
+		label := "Keep sample rate"
+		if rate > 0 {
+			label = fmt.Sprintf("%d Hz", rate)
+		}
+		rateChoice.Add(label, func() {
+			cfg.Audio.SampleRate = rate
+		})
+		if rate == cfg.Audio.SampleRate {
+			rateChoice.SetValue(i)
+		}
+	}
+
+	// Audio delay for all files, per file corrections are set in the info dialog
+	delayBox := fltk.NewBox(fltk.NO_BOX, 370, 170, 120, 30, "Audio delay (ms)")
+	delayBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	delayInput := fltk.NewIntInput(490, 170, 80, 30)
+	delayInput.SetValue(strconv.Itoa(cfg.Audio.DelayMs))
+
+	// Subtitles are dropped, extracted to sidecar files or muxed into the output
+	subBox := fltk.NewBox(fltk.NO_BOX, 370, 210, 120, 30, "Subtitles")
+	subBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	subChoice := fltk.NewChoice(450, 210, 140, 30, "")
+	for i, m := range []struct {
+		label string
+		mode  convert.SubtitleMode
+	}{
+		{"Drop", convert.SubtitlesNone},
+		{"Sidecar files", convert.SubtitlesSidecar},
+		{"Mux into output", convert.SubtitlesMux},
+	} {
+		subChoice.Add(m.label, func() {
+			cfg.Subtitles.Mode = m.mode
+		})
+		if m.mode == cfg.Subtitles.Mode {
+			subChoice.SetValue(i)
+		}
+	}
+	subChoice.SetTooltip("Sidecar files are written as SRT or SUP, DVD subtitles can only be muxed into MKV or MP4")
+	ccBox := fltk.NewBox(fltk.NO_BOX, 370, 250, 120, 30, "Closed captions")
+	ccBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	ccBtn := fltk.NewCheckButton(490, 255, 20, 20, "")
+	ccBtn.SetValue(cfg.Subtitles.Captions)
+
+	// Chapters of the source or generated every n minutes or at scene cuts
+	chapterBox := fltk.NewBox(fltk.NO_BOX, 370, 290, 120, 30, "Chapters")
+	chapterBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	chapterChoice := fltk.NewChoice(450, 290, 140, 30, "")
+	for i, m := range []struct {
+		label string
+		mode  convert.ChapterMode
+	}{
+		{"None", convert.ChaptersNone},
+		{"From source", convert.ChaptersSource},
+		{"Every n minutes", convert.ChaptersInterval},
+		{"At scene cuts", convert.ChaptersScenes},
+	} {
+		chapterChoice.Add(m.label, func() {
+			cfg.Chapters.Mode = m.mode
+		})
+		if m.mode == cfg.Chapters.Mode {
+			chapterChoice.SetValue(i)
+		}
+	}
+	intervalBox := fltk.NewBox(fltk.NO_BOX, 370, 330, 120, 30, "Chapter minutes")
+	intervalBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	intervalSpinner := fltk.NewSpinner(490, 330, 80, 30, "")
+	intervalSpinner.SetType(fltk.SPINNER_INT_INPUT)
+	intervalSpinner.SetMinimum(1)
+	intervalSpinner.SetMaximum(60)
+	intervalSpinner.SetValue(cfg.Chapters.Interval.Minutes())
+
+	// Target size switches the encoder to two passes with a calculated bit rate
+	targetBox := fltk.NewBox(fltk.NO_BOX, 370, 90, 120, 30, "Target size (MB)")
+	targetBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	targetInput := fltk.NewIntInput(490, 90, 80, 30)
+	targetInput.SetValue(strconv.Itoa(cfg.TargetMB))
+
+	// Script language loading and filtering the source
+	scriptBox := fltk.NewBox(fltk.NO_BOX, 370, 130, 80, 30, "Script")
+	scriptBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	scriptChoice := fltk.NewChoice(450, 130, 140, 30, "")
+	for i, f := range []struct {
+		label  string
+		script convert.Frameserver
+	}{
+		{"AviSynth+", convert.FrameserverAviSynth},
+		{"VapourSynth", convert.FrameserverVapourSynth},
+	} {
+		scriptChoice.Add(f.label, func() {
+			cfg.Script = f.script
+		})
+		if f.script == cfg.Script {
+			scriptChoice.SetValue(i)
+		}
+	}
+
+	// Output size, 0 keeps the size or the aspect ratio of the source
+	sizeBox := fltk.NewBox(fltk.NO_BOX, 10, 330, 120, 30, "Size (w x h)")
+	sizeBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	widthInput := fltk.NewIntInput(150, 330, 80, 30)
+	widthInput.SetValue(strconv.Itoa(cfg.Width))
+	heightInput := fltk.NewIntInput(240, 330, 80, 30)
+	heightInput.SetValue(strconv.Itoa(cfg.Height))
+
+	// Two-stage render into a lossless intermediate, stage one is reused
+	// while the filters don't change
+	stageBox := fltk.NewBox(fltk.NO_BOX, 10, 370, 120, 30, "Two-stage render")
+	stageBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	stageChoice := fltk.NewChoice(150, 370, 200, 30, "")
+	for i, c := range []struct {
+		label string
+		codec convert.LosslessCodec
+	}{
+		{"Off", convert.LosslessNone},
+		{"Huffyuv", convert.LosslessHuffyuv},
+		{"FFV1", convert.LosslessFFV1},
+		{"UT Video", convert.LosslessUTVideo},
+	} {
+		stageChoice.Add(c.label, func() {
+			cfg.TwoStage = c.codec
+		})
+		if c.codec == cfg.TwoStage {
+			stageChoice.SetValue(i)
+		}
+	}
+
+	// Verification of the output, failed checks mark the job as failed
+	verifyBox := fltk.NewBox(fltk.NO_BOX, 370, 370, 80, 30, "Verify")
+	verifyBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	verifyChoice := fltk.NewChoice(450, 370, 140, 30, "")
+	for i, v := range []struct {
+		label string
+		mode  convert.VerifyMode
+	}{
+		{"Off", convert.VerifyNone},
+		{"Probe output", convert.VerifyProbe},
+		{"Probe and decode", convert.VerifyDecode},
+	} {
+		verifyChoice.Add(v.label, func() {
+			cfg.Verify = v.mode
+		})
+		if v.mode == cfg.Verify {
+			verifyChoice.SetValue(i)
+		}
+	}
+
+	// Quality metrics of the outputs, exported as CSV per batch
+	metricsBox := fltk.NewBox(fltk.NO_BOX, 10, 410, 120, 30, "Quality metrics")
+	metricsBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	metricsBtn := fltk.NewCheckButton(150, 415, 20, 20, "")
+	metricsBtn.SetValue(cfg.Metrics)
+
+	// Outputs up to date according to the manifest of the output directory
+	skipBox := fltk.NewBox(fltk.NO_BOX, 370, 410, 120, 30, "Skip converted")
+	skipBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	skipBtn := fltk.NewCheckButton(490, 415, 20, 20, "")
+	skipBtn.SetValue(cfg.Skip)
+	skipBtn.SetTooltip("Skip files whose source and settings didn't change since the last conversion")
 
 	// Cleanup Checkbox
 	cbBox := fltk.NewBox(fltk.NO_BOX, 10, 130, 120, 30, "Clean-up files?")
@@ -133,6 +405,39 @@ func (p *ProjectConfig) Dialog() {
 	mainBox.Add(outDirBox)
 	mainBox.Add(cbBox)
 	mainBox.Add(cb)
+	mainBox.Add(codecBox)
+	mainBox.Add(codecChoice)
+	mainBox.Add(bitrateBox)
+	mainBox.Add(bitrateSpinner)
+	mainBox.Add(downmixBox)
+	mainBox.Add(downmixChoice)
+	mainBox.Add(rateBox)
+	mainBox.Add(rateChoice)
+	mainBox.Add(delayBox)
+	mainBox.Add(delayInput)
+	mainBox.Add(stageBox)
+	mainBox.Add(stageChoice)
+	mainBox.Add(verifyBox)
+	mainBox.Add(verifyChoice)
+	mainBox.Add(metricsBox)
+	mainBox.Add(metricsBtn)
+	mainBox.Add(skipBox)
+	mainBox.Add(skipBtn)
+	mainBox.Add(targetBox)
+	mainBox.Add(targetInput)
+	mainBox.Add(scriptBox)
+	mainBox.Add(scriptChoice)
+	mainBox.Add(sizeBox)
+	mainBox.Add(widthInput)
+	mainBox.Add(heightInput)
+	mainBox.Add(subBox)
+	mainBox.Add(subChoice)
+	mainBox.Add(ccBox)
+	mainBox.Add(ccBtn)
+	mainBox.Add(chapterBox)
+	mainBox.Add(chapterChoice)
+	mainBox.Add(intervalBox)
+	mainBox.Add(intervalSpinner)
 
 	// Bottom Buttons
 	bottomGroup := fltk.NewGroup(0, mainBox.H()-55, mainBox.W()-10, 40)
@@ -144,11 +449,48 @@ func (p *ProjectConfig) Dialog() {
 		dialog.Hide()
 	})
 	saveBtn.SetCallback(func() {
+		cfg.Audio.Bitrate = int(bitrateSpinner.Value())
+		cfg.Cleanup = cb.Value()
+		cfg.Metrics = metricsBtn.Value()
+		cfg.Skip = skipBtn.Value()
+		cfg.Subtitles.Captions = ccBtn.Value()
+		cfg.Chapters.Interval = time.Duration(intervalSpinner.Value()) * time.Minute
+		cfg.Width, _ = strconv.Atoi(widthInput.Value())
+		cfg.Height, _ = strconv.Atoi(heightInput.Value())
+		if delay, err := strconv.Atoi(delayInput.Value()); err == nil {
+			cfg.Audio.DelayMs = delay
+		}
+		if target, err := strconv.Atoi(targetInput.Value()); err == nil && target >= 0 {
+			cfg.TargetMB = target
+		}
+		preset, ok := presets.Get(cfg.Encoder)
+		if !ok {
+			fltk.MessageBox("Project Configuration", "unknown encoder "+cfg.Encoder)
+			return
+		}
+		if err := cfg.Audio.Validate(preset.Container); err != nil {
+			fltk.MessageBox("Project Configuration", err.Error())
+			return
+		}
 		slog.Debug("project config changed", "config", cfg)
+		p.Audio = cfg.Audio
+		p.Subtitles = cfg.Subtitles
+		p.Chapters = cfg.Chapters
+		p.Script = cfg.Script
+		p.Width = cfg.Width
+		p.Height = cfg.Height
+		p.TargetMB = cfg.TargetMB
+		p.TwoStage = cfg.TwoStage
+		p.Verify = cfg.Verify
+		p.Metrics = cfg.Metrics
+		p.Skip = cfg.Skip
 		p.Cleanup = cfg.Cleanup
 		p.Encoder = cfg.Encoder
 		p.OutputDir = cfg.OutputDir
 		p.WorkDir = cfg.WorkDir
+		if p.onSave != nil {
+			p.onSave()
+		}
 		dialog.Hide()
 	})
 	bottomGroup.Add(cancelBtn)
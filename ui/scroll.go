@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"log/slog"
 	"sort"
+	"strings"
+	"time"
 
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/archeopternix/gofltk-videoconverter/ui/virtual"
 	"github.com/archeopternix/gofltk-videoconverter/util"
 	"github.com/pwiecz/go-fltk"
 )
@@ -15,8 +19,42 @@ import (
 const (
 	scrollbarSize = 16
 	gap           = 10
+	rowHeight     = 50 // Vertical space of a row including the spacing
 )
 
+// rowLayout places the rows of the list, see virtual.List.
+var rowLayout = virtual.List{RowHeight: rowHeight, Gap: gap}
+
+// Item is a single entry of the list. Items only hold data, widgets are
+// created for the visible items only and bound to them while scrolling.
+type Item struct {
+	info     *util.MediaInfo  // Associated media info
+	selected bool             // Selected by checkbox or in the table view
+	status   string           // Processing status shown in the table view
+	metrics  *convert.Metrics // Quality metrics of the last conversion, nil if not analysed
+
+	audioTracks []int         // Stream indices of the audio tracks to convert, empty for the default track
+	audioDelay  time.Duration // Audio delay correction of this file
+	trimStart   time.Duration // Start of the converted part
+	trimEnd     time.Duration // End of the converted part, 0 for the end of the file
+	archiveDir  string        // Directory the source is moved to after the conversion, empty keeps it
+}
+
+// SetStatus sets the processing status of the item.
+func (it *Item) SetStatus(status string) {
+	it.status = status
+}
+
+// SetMetrics sets the quality metrics of the converted file.
+func (it *Item) SetMetrics(m *convert.Metrics) {
+	it.metrics = m
+}
+
+// Status returns the processing status of the item.
+func (it *Item) Status() string {
+	return it.status
+}
+
 // Row represents a single row in the scrollable area.
 type Row struct {
 	group     *fltk.Group       // Group container for row components
@@ -25,12 +63,14 @@ type Row struct {
 	namelabel *fltk.Box         // Label for displaying the name
 	label     *fltk.Box         // Label for additional details
 	button    *fltk.Button      // Button for performing an action
-	info      *util.Info        // Associated info object
+	preview   *fltk.Button      // Button opening the preview window
+	item      *Item             // Item currently shown by the row
+	metrics   *convert.Metrics  // Quality metrics currently shown by the row
 }
 
-// NewRow creates and returns a new Row instance with the specified info.
-func NewRow(info *util.Info) *Row {
-	rowHeight := 50
+// NewRow creates and returns a new Row instance. Use bind to show an item.
+// The preview function is called with the item when Preview is pressed.
+func NewRow(preview func(*Item)) *Row {
 	row := fltk.NewGroup(0, 0, 330, rowHeight)
 	row.Begin()
 
@@ -38,41 +78,76 @@ func NewRow(info *util.Info) *Row {
 	cb := fltk.NewCheckButton(10, 10, 20, 20, "")
 	img := fltk.NewBox(fltk.FLAT_BOX, 40, 5, 40, 40, "")
 	img.SetColor(fltk.BLUE)
-	namelbl := fltk.NewBox(fltk.NO_BOX, 90, 5, 150, 20, fmt.Sprintf("%s", info.Name))
+	namelbl := fltk.NewBox(fltk.NO_BOX, 90, 5, 150, 20, "")
 	namelbl.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
 
-	lbl := fltk.NewBox(fltk.NO_BOX, 90, 22, 150, 37, fmt.Sprintf("(%dx%d / %s FPS)", info.ResolutionX, info.ResolutionY, info.FPS))
+	lbl := fltk.NewBox(fltk.NO_BOX, 90, 22, 150, 37, "")
 	lbl.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
 
 	btn := fltk.NewButton(250, 10, 70, 30, "Info...")
-	btn.SetCallback(func() {
-		fmt.Println("Video Info Dialog called")
-		//		videoInfoDialog(info)
-	})
+	previewBtn := fltk.NewButton(170, 10, 70, 30, "Preview")
 
 	row.End()
 
-	// Return the new Row instance
-	return &Row{
+	r := &Row{
 		group:     row,
 		checkbox:  cb,
 		image:     img,
 		namelabel: namelbl,
 		label:     lbl,
 		button:    btn,
-		info:      info,
+		preview:   previewBtn,
 	}
+
+	// The callbacks refer to the item bound at the time of the event
+	cb.SetCallback(func() {
+		if r.item != nil {
+			r.item.selected = cb.Value()
+		}
+	})
+	btn.SetCallback(func() {
+		if r.item != nil {
+			videoInfoDialog(r.item)
+		}
+	})
+	previewBtn.SetCallback(func() {
+		if r.item != nil {
+			preview(r.item)
+		}
+	})
+
+	return r
+}
+
+// bind shows the item in the row. Labels are only updated when the item
+// or its quality metrics changed to keep scrolling cheap.
+func (r *Row) bind(item *Item) {
+	r.checkbox.SetValue(item.selected)
+	if r.item == item && r.metrics == item.metrics {
+		return
+	}
+	r.item, r.metrics = item, item.metrics
+	info := item.info
+	v := info.FirstVideo()
+	r.namelabel.SetLabel(info.Name)
+	details := fmt.Sprintf("(%s / %s FPS)", util.FormatResolution(v), util.FormatFrameRate(v))
+	r.label.SetTooltip("")
+	if m := item.metrics; m != nil {
+		details += "  " + m.Summary()
+		r.label.SetTooltip(m.String())
+	}
+	r.label.SetLabel(details)
 }
 
 // Refresh updates the position and size of the row and its components.
 func (r *Row) Refresh(x, y, width int) {
-	rowHeight := 48
-	r.group.Resize(x, y, width, rowHeight)
+	r.group.Resize(x, y, width, rowHeight-2)
 	r.checkbox.Resize(gap, y+10, 20, 20)
 	r.image.Resize(30+gap, y+5, 40, 40)
-	r.namelabel.Resize(70+gap, y+5, width-70-10-15-90, 20)
-	r.label.Resize(70+gap, y+22, width-70-2*gap-90, 20)
+	r.namelabel.Resize(70+gap, y+5, width-70-10-15-170, 20)
+	r.label.Resize(70+gap, y+22, width-70-2*gap-170, 20)
 	r.button.Resize(width-70-2*gap, y+10, 70, 30)
+	r.preview.Resize(width-150-2*gap, y+10, 70, 30)
 
 	// Make components visible
 	r.checkbox.Show()
@@ -80,93 +155,138 @@ func (r *Row) Refresh(x, y, width int) {
 	r.namelabel.Show()
 	r.label.Show()
 	r.button.Show()
+	r.preview.Show()
 	r.group.Show()
 }
 
-// Scroll represents a scrollable container with rows.
+// hide hides the row and all of its components.
+func (r *Row) hide() {
+	r.group.Hide()
+	r.checkbox.Hide()
+	r.image.Hide()
+	r.namelabel.Hide()
+	r.label.Hide()
+	r.button.Hide()
+	r.preview.Hide()
+}
+
+// Scroll represents a scrollable container with rows. Only the items inside
+// the visible area get a Row widget, so the layout cost depends on the
+// height of the container and not on the number of items.
 type Scroll struct {
-	fltkScroll *fltk.Scroll // The underlying FLTK scroll widget
-	rows       []*Row       // List of rows in the scroll
-	lastW      int          // Last recorded width of the scroll container
-	lastH      int          // Last recorded height of the scroll container
+	fltkScroll *fltk.Scroll    // The underlying FLTK scroll widget
+	spacer     *fltk.Box       // Invisible box with the height of all items for the scrollbar
+	items      []*Item         // All items of the list
+	paths      map[string]bool // Full paths of all items to detect duplicates
+	shown      []*Item         // Items matching the filter in sort order
+	rows       []*Row          // Pool of row widgets for the visible area
+	lastY      int             // Last laid out scroll position
+	filter     string          // Text filter, only matching items are shown
+	sortCol    Column          // Column the items are sorted by
+	sortDesc   bool            // Sort in descending order
+	onChange   func()          // Called when items, filter or sort order changed
+	onPreview  func(*Item)     // Called when the preview of an item is requested
+}
+
+// dropHandler returns an event handler accepting drag and drop of files.
+// The dropped text (file URIs or newline separated paths) is converted to
+// paths and passed to the drop function.
+func dropHandler(drop func(paths []string)) func(fltk.Event) bool {
+	return func(event fltk.Event) bool {
+		switch event {
+		case fltk.DND_ENTER, fltk.DND_DRAG, fltk.DND_LEAVE, fltk.DND_RELEASE:
+			return true
+		case fltk.PASTE:
+			paths := util.ParseDroppedText(fltk.EventText())
+			slog.Debug("files dropped", "paths", paths)
+			if len(paths) > 0 {
+				drop(paths)
+			}
+			return true
+		}
+		return false
+	}
 }
 
 // NewScroll creates a new Scroll instance with specified dimensions.
 func NewScroll(x, y, w, h int) *Scroll {
 	scroll := fltk.NewScroll(x, y, w, h)
 	scroll.Begin()
+	spacer := fltk.NewBox(fltk.NO_BOX, x, y, 1, 1, "")
 	scroll.End()
 
 	s := &Scroll{
 		fltkScroll: scroll,
-		rows:       []*Row{},
-		lastW:      w,
-		lastH:      h,
+		spacer:     spacer,
+		items:      []*Item{},
+		paths:      map[string]bool{},
+		sortCol:    ColumnNone,
 	}
 
-	// Start monitoring for resize events
-	s.startResizeWatcher()
+	// Lay out the rows when the container is resized or scrolled
+	scroll.SetResizeHandler(s.Refresh)
+	scroll.SetDrawHandler(func(baseDraw func()) {
+		if scroll.YPosition() != s.lastY {
+			s.layout()
+		}
+		baseDraw()
+	})
 
 	return s
 }
 
-// AddRow adds a new Row to the scroll container.
-func (s *Scroll) AddRow(info *util.Info) {
-	// Avoid adding duplicate rows
-	for _, r := range s.rows {
-		if r.info.FullPath == info.FullPath {
-			slog.Debug("duplicate row", "filepath", info.FullPath)
-			return
-		}
+// AddRow adds a new item to the scroll container and returns it, nil if
+// the file is already in the list.
+func (s *Scroll) AddRow(info *util.MediaInfo) *Item {
+	if info == nil {
+		return nil
 	}
 
-	// Create a new row
-	row := NewRow(info)
+	// Avoid adding duplicate rows
+	if s.paths[info.FullPath] {
+		slog.Debug("duplicate row", "filepath", info.FullPath)
+		return nil
+	}
 
-	// Add the row to the scroll container
-	s.fltkScroll.Begin()
-	s.fltkScroll.Add(row.group)
-	s.rows = append(s.rows, row)
-	s.Refresh()
-	s.fltkScroll.End()
-	s.fltkScroll.Redraw()
+	item := &Item{info: info, status: "new"}
+	s.items = append(s.items, item)
+	s.paths[info.FullPath] = true
+	s.update()
 	slog.Debug("row added", "filepath", info.FullPath)
+	return item
 }
 
-// DeleteRow removes a row at the specified index.
+// MoveItem changes the path of the item to the new location of its file.
+// The item gets a copy of the media info, jobs of a running batch keep the
+// old one.
+func (s *Scroll) MoveItem(item *Item, path string) {
+	delete(s.paths, item.info.FullPath)
+	info := *item.info
+	info.FullPath = path
+	item.info = &info
+	s.paths[path] = true
+}
+
+// DeleteRow removes the item at the specified index.
 func (s *Scroll) DeleteRow(index int) {
-	if index < 0 || index >= len(s.rows) {
+	if index < 0 || index >= len(s.items) {
 		return // Invalid index
 	}
 
-	// Hide all components of the row
-	row := s.rows[index]
-	row.group.Hide()
-	row.checkbox.Hide()
-	row.image.Hide()
-	row.namelabel.Hide()
-	row.label.Hide()
-	row.button.Hide()
-
-	slog.Debug("row deleted", "filepath", row.info.FullPath)
-
-	// Remove the row from the list
-	s.rows = append(s.rows[:index], s.rows[index+1:]...)
-
-	// Refresh the scroll container after deletion
-	s.fltkScroll.Begin()
-	s.Refresh()
-	s.fltkScroll.End()
-	s.fltkScroll.Redraw()
+	slog.Debug("row deleted", "filepath", s.items[index].info.FullPath)
+	delete(s.paths, s.items[index].info.FullPath)
 
+	// Remove the item from the list
+	s.items = append(s.items[:index], s.items[index+1:]...)
+	s.update()
 }
 
-// GetSelectedRows returns the indices of selected rows in reverse order
+// GetSelectedRows returns the indices of selected items in reverse order
 // to support deletion of multiple files.
 func (s *Scroll) GetSelectedRows() []int {
 	var selected []int
-	for i, r := range s.rows {
-		if r.checkbox.Value() {
+	for i, it := range s.items {
+		if it.selected {
 			selected = append(selected, i)
 		}
 	}
@@ -176,61 +296,152 @@ func (s *Scroll) GetSelectedRows() []int {
 	return selected
 }
 
-// startResizeWatcher monitors the scroll container for size changes and triggers a refresh.
-func (s *Scroll) startResizeWatcher() {
-	const interval = 0.1 // Check every 200ms
+// OnChange registers a function that is called whenever rows are added or
+// deleted, or the filter or the sort order changes.
+func (s *Scroll) OnChange(f func()) {
+	s.onChange = f
+}
+
+// OnPreview sets the function opening the preview window of an item.
+func (s *Scroll) OnPreview(f func(*Item)) {
+	s.onPreview = f
+}
+
+// OnDrop accepts files and folders dropped onto the scroll container and
+// passes their paths to the drop function.
+func (s *Scroll) OnDrop(drop func(paths []string)) {
+	s.fltkScroll.SetEventHandler(dropHandler(drop))
+}
+
+// SetFilter shows only rows matching the text (case insensitive).
+func (s *Scroll) SetFilter(text string) {
+	s.filter = strings.TrimSpace(text)
+	s.update()
+}
+
+// SortBy sorts the rows by the given column. Sorting twice by the same
+// column toggles between ascending and descending order.
+func (s *Scroll) SortBy(col Column) {
+	if s.sortCol == col {
+		s.sortDesc = !s.sortDesc
+	} else {
+		s.sortCol = col
+		s.sortDesc = false
+	}
+	s.update()
+}
+
+// SortOrder returns the column the rows are sorted by and the direction.
+func (s *Scroll) SortOrder() (Column, bool) {
+	return s.sortCol, s.sortDesc
+}
+
+// VisibleItems returns the items matching the filter in sort order.
+func (s *Scroll) VisibleItems() []*Item {
+	return s.shown
+}
+
+// Items returns all items in the order they were added.
+func (s *Scroll) Items() []*Item {
+	return s.items
+}
 
-	// Add a timeout to monitor for changes in width and height
-	fltk.AddTimeout(interval, func() {
-		curW := s.fltkScroll.W()
-		curH := s.fltkScroll.H()
+// SelectedItems returns the selected items in the order they were added.
+func (s *Scroll) SelectedItems() []*Item {
+	var selected []*Item
+	for _, it := range s.items {
+		if it.selected {
+			selected = append(selected, it)
+		}
+	}
+	return selected
+}
 
-		// Trigger a refresh if dimensions have changed
-		if curW != s.lastW || curH != s.lastH {
-			s.lastW = curW
-			s.lastH = curH
-			s.fltkScroll.Begin()
-			s.Refresh()
-			s.fltkScroll.End()
-			s.fltkScroll.Redraw()
+// update filters and sorts the items, lays out the rows and notifies the
+// change handler. Called whenever the items, the filter or the order change.
+func (s *Scroll) update() {
+	shown := make([]*Item, 0, len(s.items))
+	for _, it := range s.items {
+		if it.matches(s.filter) {
+			shown = append(shown, it)
 		}
+	}
+	if s.sortCol != ColumnNone {
+		sort.SliceStable(shown, func(i, j int) bool {
+			if s.sortDesc {
+				return lessItem(shown[j], shown[i], s.sortCol)
+			}
+			return lessItem(shown[i], shown[j], s.sortCol)
+		})
+	}
+	s.shown = shown
 
-		// Restart the watcher
-		s.startResizeWatcher()
-	})
+	s.Refresh()
+	if s.onChange != nil {
+		s.onChange()
+	}
 }
 
-// Refresh rearranges the rows in the scroll container and adjusts dimensions.
+// Refresh adjusts the content height and the number of row widgets to the
+// size of the scroll container and lays out the visible rows.
 func (s *Scroll) Refresh() {
-	y := 10 + 25 // Starting Y-coordinate for the first row
+	// Enough rows to fill the viewport, partially visible rows at both ends
+	needed := rowLayout.PoolSize(s.fltkScroll.H())
+	if len(s.rows) < needed {
+		s.fltkScroll.Begin()
+		for len(s.rows) < needed {
+			r := NewRow(func(it *Item) {
+				if s.onPreview != nil {
+					s.onPreview(it)
+				}
+			})
+			r.hide()
+			s.rows = append(s.rows, r)
+		}
+		s.fltkScroll.End()
+	}
+
+	s.layout()
+	s.fltkScroll.Redraw()
+}
+
+// layout binds the visible items to the row widgets and positions them.
+// The cost only depends on the number of visible rows.
+func (s *Scroll) layout() {
+	ypos := s.fltkScroll.YPosition()
+	s.lastY = ypos
+	top := s.fltkScroll.Y() - ypos // Y-coordinate of the content start
 
-	// Estimate the total height of the content
-	contentHeight := len(s.rows)*50 + 10
+	// The spacer spans the whole content so that the scrollbar covers all items
+	contentHeight := rowLayout.ContentHeight(len(s.shown))
+	s.spacer.Resize(s.fltkScroll.X(), top, 1, contentHeight)
 
 	// Adjust for the vertical scrollbar if needed
 	scrollbarWidth := 0
 	if contentHeight > s.fltkScroll.H() {
 		scrollbarWidth = scrollbarSize
 	}
-
 	width := s.fltkScroll.W() - scrollbarWidth
 
-	// Resize and show each row independently
-	for _, r := range s.rows {
-		r.Refresh(0, y, width)
-		y += 50
-	}
+	rowLayout.Layout(ypos, s.fltkScroll.H(), len(s.shown), len(s.rows), func(row, index, y int) {
+		r := s.rows[row]
+		if index < 0 {
+			r.item = nil
+			r.hide()
+			return
+		}
+		r.bind(s.shown[index])
+		r.Refresh(0, top+y, width)
+	})
 }
 
-// GetFiles gets all selected files
+// GetSelectedFilePaths returns the paths of all selected files
 func (s Scroll) GetSelectedFilePaths() []string {
 	var files []string
 
-	rows := s.rows
-	for i := 0; i < len(rows); i++ {
-		if rows[i].checkbox.IsActive() {
-			info := rows[i].info
-			files = append(files, info.FullPath) // Assumes info has a FilePath field
+	for _, it := range s.items {
+		if it.selected {
+			files = append(files, it.info.FullPath)
 		}
 	}
 	return files
@@ -1,27 +1,43 @@
 package ui
 
 import (
+	"context"
 	"log/slog"
 
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/archeopternix/gofltk-videoconverter/util"
 	"github.com/pwiecz/go-fltk"
 )
 
 type SystemConfig struct {
 	AvisynthPlugInPath string // path to AviSynth plugins
 	VirtualDubPath     string // path to VirtualDub
+	FFmpegPath         string // path to ffmpeg, used for muxing and analysis
+	FFprobePath        string // path to ffprobe, used to read the media info
+	PresetPath         string // directory with user defined encoder presets (*.yaml)
+	Backend            string // encoding backend, convert.BackendVirtualDub or convert.BackendFFmpeg
+	VSPipePath         string // path to vspipe of VapourSynth
+	VapourSynthPlugins string // path to VapourSynth plugins
+	WinePath           string // wine launcher running VirtualDub2 on Linux, empty runs it natively
+	WinePrefix         string // WINEPREFIX for the wine launcher, empty for the default
+	PathMappings       string // path prefixes translated in the scripts, "from=to;..."
+	DetectDuplicates   bool   // compare imported files by content with the list and the output directory
 }
 
 func NewSystemConfig(avis, vdub string) SystemConfig {
-	return SystemConfig{AvisynthPlugInPath: avis, VirtualDubPath: vdub}
+	return SystemConfig{AvisynthPlugInPath: avis, VirtualDubPath: vdub, FFmpegPath: "ffmpeg.exe", PresetPath: "presets",
+		Backend: convert.BackendVirtualDub, VSPipePath: "vspipe", FFprobePath: util.DefaultFFprobePath()}
 }
 
 //var SysCfg *SystemConfig
 
 // vdubConfigDialog creates and displays a modal dialog window
 // to edit path to VirtualDub2, working and output directory and the used encoder.
-func (s *SystemConfig) Dialog() {
+// The installation is checked with the edited paths for the frameserver of
+// the project.
+func (s *SystemConfig) Dialog(fs convert.Frameserver) {
 	// Create a modal window
-	dialog := fltk.NewWindow(600, 220, "System Configuration")
+	dialog := fltk.NewWindow(600, 540, "System Configuration")
 	dialog.SetModal() // Set the window as modal
 	dialog.Begin()
 
@@ -29,6 +45,16 @@ func (s *SystemConfig) Dialog() {
 	cfg := SystemConfig{
 		AvisynthPlugInPath: s.AvisynthPlugInPath,
 		VirtualDubPath:     s.VirtualDubPath,
+		FFmpegPath:         s.FFmpegPath,
+		FFprobePath:        s.FFprobePath,
+		PresetPath:         s.PresetPath,
+		Backend:            s.Backend,
+		VSPipePath:         s.VSPipePath,
+		VapourSynthPlugins: s.VapourSynthPlugins,
+		WinePath:           s.WinePath,
+		WinePrefix:         s.WinePrefix,
+		PathMappings:       s.PathMappings,
+		DetectDuplicates:   s.DetectDuplicates,
 	}
 
 	// Create a vertical box for layout
@@ -90,10 +116,233 @@ func (s *SystemConfig) Dialog() {
 		}
 	})
 
+	// Path to ffmpeg
+	ffmpegBox := fltk.NewBox(fltk.NO_BOX, 10, 90, 400, 30, "")
+	if cfg.FFmpegPath == "" {
+		ffmpegBox.SetLabel("No file selected")
+	} else {
+		ffmpegBox.SetLabel(cfg.FFmpegPath)
+	}
+
+	ffmpegBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	ffmpegBtn := fltk.NewButton(410, 90, 140, 30, "ffmpeg path")
+	ffmpegBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.FFmpegPath,
+			"ffmpeg*.*",
+			fltk.FileChooser_SINGLE,
+			"Choose ffmpeg executable")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			ffmpegBox.SetLabel(chooser.Selection()[0])
+			cfg.FFmpegPath = chooser.Selection()[0]
+		}
+	})
+
+	// Directory of the encoder presets
+	presetDirBox := fltk.NewBox(fltk.NO_BOX, 10, 130, 400, 30, "")
+	if cfg.PresetPath == "" {
+		presetDirBox.SetLabel("No directory selected")
+	} else {
+		presetDirBox.SetLabel(cfg.PresetPath)
+	}
+	presetDirBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	presetDirBtn := fltk.NewButton(410, 130, 140, 30, "Encoder presets")
+	presetDirBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.PresetPath,
+			"*.*",
+			fltk.FileChooser_DIRECTORY,
+			"Choose Encoder Presets Directory")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			presetDirBox.SetLabel(chooser.Selection()[0])
+			cfg.PresetPath = chooser.Selection()[0]
+		}
+	})
+
+	// Path to vspipe, renders VapourSynth scripts for ffmpeg
+	vspipeBox := fltk.NewBox(fltk.NO_BOX, 10, 170, 400, 30, "")
+	if cfg.VSPipePath == "" {
+		vspipeBox.SetLabel("No file selected")
+	} else {
+		vspipeBox.SetLabel(cfg.VSPipePath)
+	}
+	vspipeBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	vspipeBtn := fltk.NewButton(410, 170, 140, 30, "vspipe path")
+	vspipeBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.VSPipePath,
+			"vspipe*",
+			fltk.FileChooser_SINGLE,
+			"Choose vspipe executable")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			vspipeBox.SetLabel(chooser.Selection()[0])
+			cfg.VSPipePath = chooser.Selection()[0]
+		}
+	})
+
+	// VapourSynth plugin directory
+	vsDirBox := fltk.NewBox(fltk.NO_BOX, 10, 210, 400, 30, "")
+	if cfg.VapourSynthPlugins == "" {
+		vsDirBox.SetLabel("No directory selected")
+	} else {
+		vsDirBox.SetLabel(cfg.VapourSynthPlugins)
+	}
+	vsDirBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	vsDirBtn := fltk.NewButton(410, 210, 140, 30, "VapourSynth plugins")
+	vsDirBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.VapourSynthPlugins,
+			"*.*",
+			fltk.FileChooser_DIRECTORY,
+			"Choose VapourSynth Plugins Directory")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			vsDirBox.SetLabel(chooser.Selection()[0])
+			cfg.VapourSynthPlugins = chooser.Selection()[0]
+		}
+	})
+
+	// Encoding backend
+	backendBox := fltk.NewBox(fltk.NO_BOX, 10, 250, 120, 30, "Encoding backend")
+	backendBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	backendChoice := fltk.NewChoice(150, 250, 200, 30, "")
+	for i, b := range []struct {
+		label string
+		name  string
+	}{
+		{"AviSynth+ / VirtualDub2", convert.BackendVirtualDub},
+		{"ffmpeg", convert.BackendFFmpeg},
+	} {
+		backendChoice.Add(b.label, func() {
+			cfg.Backend = b.name
+		})
+		if b.name == cfg.Backend {
+			backendChoice.SetValue(i)
+		}
+	}
+
 	mainBox.Add(avsDirBtn)
 	mainBox.Add(avsDirBox)
 	mainBox.Add(vdubDirBtn)
 	mainBox.Add(vdubDirBox)
+	mainBox.Add(ffmpegBtn)
+	mainBox.Add(ffmpegBox)
+	mainBox.Add(presetDirBtn)
+	mainBox.Add(presetDirBox)
+	mainBox.Add(vspipeBtn)
+	mainBox.Add(vspipeBox)
+	mainBox.Add(vsDirBtn)
+	mainBox.Add(vsDirBox)
+	// Wine runs VirtualDub2 and AviSynth+ on Linux, paths in the scripts
+	// are translated to the Z: drive
+	wineBox := fltk.NewBox(fltk.NO_BOX, 10, 290, 120, 30, "Wine launcher")
+	wineBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	wineInput := fltk.NewInput(150, 290, 400, 30)
+	wineInput.SetValue(cfg.WinePath)
+	wineInput.SetTooltip("Like \"wine\", empty runs VirtualDub2 natively")
+	prefixBox := fltk.NewBox(fltk.NO_BOX, 10, 330, 120, 30, "WINEPREFIX")
+	prefixBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	prefixInput := fltk.NewInput(150, 330, 400, 30)
+	prefixInput.SetValue(cfg.WinePrefix)
+	prefixInput.SetTooltip("Empty uses the default prefix")
+
+	// Paths in the scripts for VirtualDub2 on another system
+	mappingBox := fltk.NewBox(fltk.NO_BOX, 10, 370, 120, 30, "Path mappings")
+	mappingBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	mappingInput := fltk.NewInput(150, 370, 400, 30)
+	mappingInput.SetValue(cfg.PathMappings)
+	mappingInput.SetTooltip("Translated path prefixes like /mnt/videos=\\\\nas\\videos, separated by ;")
+
+	// Path to ffprobe
+	ffprobeBox := fltk.NewBox(fltk.NO_BOX, 10, 410, 400, 30, "")
+	if cfg.FFprobePath == "" {
+		ffprobeBox.SetLabel("No file selected")
+	} else {
+		ffprobeBox.SetLabel(cfg.FFprobePath)
+	}
+	ffprobeBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	ffprobeBtn := fltk.NewButton(410, 410, 140, 30, "ffprobe path")
+	ffprobeBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.FFprobePath,
+			"ffprobe*.*",
+			fltk.FileChooser_SINGLE,
+			"Choose ffprobe executable")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			ffprobeBox.SetLabel(chooser.Selection()[0])
+			cfg.FFprobePath = chooser.Selection()[0]
+		}
+	})
+
+	// Copies of a file in another folder are detected by their content
+	dupBox := fltk.NewBox(fltk.NO_BOX, 200, 450, 200, 30, "Detect duplicates by content")
+	dupBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	dupBtn := fltk.NewCheckButton(410, 455, 20, 20, "")
+	dupBtn.SetValue(cfg.DetectDuplicates)
+
+	// readInputs takes the values of the input fields into cfg, invalid
+	// path mappings are reported
+	readInputs := func() bool {
+		cfg.WinePath, cfg.WinePrefix = wineInput.Value(), prefixInput.Value()
+		cfg.PathMappings = mappingInput.Value()
+		cfg.DetectDuplicates = dupBtn.Value()
+		if _, err := convert.ParsePathMappings(cfg.PathMappings); err != nil {
+			fltk.MessageBox("System Configuration", err.Error())
+			return false
+		}
+		return true
+	}
+
+	// Check the edited paths before saving them
+	checkBtn := fltk.NewButton(10, 450, 140, 30, "Check installation")
+	checkBtn.SetCallback(func() {
+		if readInputs() {
+			healthDialog(cfg.Check(fs))
+		}
+	})
+
+	mainBox.Add(backendBox)
+	mainBox.Add(backendChoice)
+	mainBox.Add(wineBox)
+	mainBox.Add(wineInput)
+	mainBox.Add(prefixBox)
+	mainBox.Add(prefixInput)
+	mainBox.Add(mappingBox)
+	mainBox.Add(mappingInput)
+	mainBox.Add(ffprobeBox)
+	mainBox.Add(ffprobeBtn)
+	mainBox.Add(checkBtn)
+	mainBox.Add(dupBox)
+	mainBox.Add(dupBtn)
 
 	// Bottom Buttons
 	bottomGroup := fltk.NewGroup(0, mainBox.H()-55, mainBox.W()-10, 40)
@@ -105,9 +354,23 @@ func (s *SystemConfig) Dialog() {
 		dialog.Hide()
 	})
 	saveBtn.SetCallback(func() {
+		if !readInputs() {
+			return
+		}
 		slog.Debug("System config changed", "config", cfg)
 		s.AvisynthPlugInPath = cfg.AvisynthPlugInPath
 		s.VirtualDubPath = cfg.VirtualDubPath
+		s.FFmpegPath = cfg.FFmpegPath
+		s.FFprobePath = cfg.FFprobePath
+		util.SetFFprobePath(s.FFprobePath)
+		s.PresetPath = cfg.PresetPath
+		s.Backend = cfg.Backend
+		s.VSPipePath = cfg.VSPipePath
+		s.VapourSynthPlugins = cfg.VapourSynthPlugins
+		s.WinePath = cfg.WinePath
+		s.WinePrefix = cfg.WinePrefix
+		s.PathMappings = cfg.PathMappings
+		s.DetectDuplicates = cfg.DetectDuplicates
 		dialog.Hide()
 	})
 	bottomGroup.Add(cancelBtn)
@@ -118,3 +381,33 @@ func (s *SystemConfig) Dialog() {
 	dialog.End()
 	dialog.Show()
 }
+
+// NewBackend returns the configured encoding backend.
+func (s *SystemConfig) NewBackend() convert.Backend {
+	return convert.NewBackend(s.Backend, s.Tools())
+}
+
+// Tools returns the configured paths of the external programs. Invalid
+// path mappings are rejected by the dialog and ignored here.
+func (s *SystemConfig) Tools() convert.Tools {
+	mappings, _ := convert.ParsePathMappings(s.PathMappings)
+	return convert.Tools{
+		FFmpeg:             s.FFmpegPath,
+		VirtualDub:         s.VirtualDubPath,
+		VSPipe:             s.VSPipePath,
+		AviSynthPlugins:    s.AvisynthPlugInPath,
+		VapourSynthPlugins: s.VapourSynthPlugins,
+		Wine:               convert.Wine{Launcher: s.WinePath, Prefix: s.WinePrefix},
+		PathMappings:       mappings,
+	}
+}
+
+// Check checks the external programs and plugins for the backend and the
+// frameserver.
+func (s *SystemConfig) Check(fs convert.Frameserver) []convert.ToolCheck {
+	ffprobe := s.FFprobePath
+	if ffprobe == "" {
+		ffprobe = util.DefaultFFprobePath()
+	}
+	return convert.CheckInstallation(context.Background(), s.Tools(), ffprobe, s.Backend, fs)
+}
@@ -0,0 +1,224 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+	"github.com/pwiecz/go-fltk"
+)
+
+// Column identifies a column of the file table.
+type Column int
+
+const (
+	ColumnNone Column = iota - 1 // No sorting, rows keep the insertion order
+	ColumnName
+	ColumnDuration
+	ColumnSize
+	ColumnCodec
+	ColumnResolution
+	ColumnFPS
+	ColumnFieldOrder
+	ColumnStatus
+	ColumnQuality
+)
+
+// columnTitles holds the header text and the initial width of each column.
+var columnTitles = []struct {
+	title string
+	width int
+}{
+	{"Name", 180},
+	{"Duration", 70},
+	{"Size", 70},
+	{"Codec", 70},
+	{"Resolution", 80},
+	{"FPS", 55},
+	{"Field order", 80},
+	{"Status", 80},
+	{"Quality", 160},
+}
+
+// String returns the header text of the column.
+func (c Column) String() string {
+	if c < 0 || int(c) >= len(columnTitles) {
+		return ""
+	}
+	return columnTitles[c].title
+}
+
+// cellText returns the text shown for the item in the given column.
+func (it *Item) cellText(col Column) string {
+	info := it.info
+	v := info.FirstVideo()
+	switch col {
+	case ColumnName:
+		return info.Name
+	case ColumnDuration:
+		return mediatime.FormatDuration(info.Length())
+	case ColumnSize:
+		return util.FormatSize(info.Size)
+	case ColumnCodec:
+		if v != nil {
+			return v.Codec
+		}
+	case ColumnResolution:
+		return util.FormatResolution(v)
+	case ColumnFPS:
+		return util.FormatFrameRate(v)
+	case ColumnFieldOrder:
+		if v != nil {
+			return string(v.FieldOrder)
+		}
+	case ColumnStatus:
+		return it.status
+	case ColumnQuality:
+		if it.metrics != nil {
+			return it.metrics.String()
+		}
+	}
+	return ""
+}
+
+// matches returns true when the filter text is contained in any of the
+// displayed values of the item or in the container format.
+func (it *Item) matches(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	filter = strings.ToLower(filter)
+	if strings.Contains(strings.ToLower(it.info.Container), filter) {
+		return true
+	}
+	for col := range columnTitles {
+		if strings.Contains(strings.ToLower(it.cellText(Column(col))), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// lessItem compares two items by the values of the given column. Numeric
+// columns are compared by value and not by their formatted text.
+func lessItem(a, b *Item, col Column) bool {
+	va, vb := a.info.FirstVideo(), b.info.FirstVideo()
+	switch col {
+	case ColumnSize:
+		return a.info.Size < b.info.Size
+	case ColumnDuration:
+		return a.info.Length() < b.info.Length()
+	case ColumnResolution:
+		if va != nil && vb != nil {
+			return va.Width*va.Height < vb.Width*vb.Height
+		}
+	case ColumnFPS:
+		if va != nil && vb != nil {
+			return va.FrameRate.Float64() < vb.FrameRate.Float64()
+		}
+	}
+	return strings.ToLower(a.cellText(col)) < strings.ToLower(b.cellText(col))
+}
+
+// Table shows the items of a Scroll as a table with sortable columns.
+// Filter, sort order and selection are shared with the Scroll.
+type Table struct {
+	table   *fltk.TableRow // The underlying FLTK table widget
+	lister  *Scroll        // List holding the items
+	visible []*Item        // Items currently displayed in display order
+}
+
+// NewTable creates a new table view for the items of the Scroll.
+func NewTable(x, y, w, h int, lister *Scroll) *Table {
+	t := &Table{
+		table:  fltk.NewTableRow(x, y, w, h),
+		lister: lister,
+	}
+	t.table.EnableColumnHeaders()
+	t.table.AllowColumnResizing()
+	t.table.SetColumnHeaderHeight(25)
+	t.table.SetColumnCount(len(columnTitles))
+	for i, c := range columnTitles {
+		t.table.SetColumnWidth(i, c.width)
+	}
+	t.table.SetType(fltk.SelectMulti)
+	t.table.SetDrawCellCallback(t.drawCell)
+	t.table.SetCallbackCondition(fltk.WhenRelease)
+	t.table.SetCallback(t.onClick)
+	t.table.End()
+
+	t.Refresh()
+	return t
+}
+
+// Show makes the table visible.
+func (t *Table) Show() {
+	t.Refresh()
+	t.table.Show()
+}
+
+// Hide hides the table.
+func (t *Table) Hide() {
+	t.table.Hide()
+}
+
+// Refresh reloads the items from the Scroll and restores the selection.
+func (t *Table) Refresh() {
+	t.visible = t.lister.VisibleItems()
+	t.table.SetRowCount(len(t.visible))
+	t.table.SetRowHeightAll(22)
+	for i, it := range t.visible {
+		flag := fltk.Deselect
+		if it.selected {
+			flag = fltk.Select
+		}
+		t.table.SelectRow(i, flag)
+	}
+	t.table.Redraw()
+}
+
+// onClick sorts the items when a column header was clicked and copies the
+// table selection to the items when a cell was clicked.
+func (t *Table) onClick() {
+	switch t.table.CallbackContext() {
+	case fltk.ContextColHeader:
+		t.lister.SortBy(Column(t.table.CallbackColumn()))
+	case fltk.ContextCell:
+		for i, it := range t.visible {
+			it.selected = t.table.IsRowSelected(i)
+		}
+	}
+}
+
+// drawCell draws the column headers and the cells of the table.
+func (t *Table) drawCell(tc fltk.TableContext, row, col, x, y, w, h int) {
+	switch tc {
+	case fltk.ContextColHeader:
+		label := Column(col).String()
+		if sortCol, desc := t.lister.SortOrder(); sortCol == Column(col) {
+			if desc {
+				label += " ▼"
+			} else {
+				label += " ▲"
+			}
+		}
+		fltk.SetDrawFont(fltk.HELVETICA_BOLD, labelSize)
+		fltk.DrawBox(fltk.UP_BOX, x, y, w, h, fltk.BACKGROUND_COLOR)
+		fltk.SetDrawColor(fltk.BLACK)
+		fltk.Draw(label, x+4, y, w-8, h, fltk.ALIGN_LEFT)
+	case fltk.ContextCell:
+		if row >= len(t.visible) {
+			return
+		}
+		bg := fltk.WHITE
+		if t.table.IsRowSelected(row) {
+			bg = fltk.SELECTION_COLOR
+		}
+		fltk.SetDrawFont(fltk.HELVETICA, labelSize)
+		fltk.DrawBox(fltk.FLAT_BOX, x, y, w, h, bg)
+		fltk.SetDrawColor(fltk.BLACK)
+		fltk.PushClip(x, y, w, h)
+		fltk.Draw(t.visible[row].cellText(Column(col)), x+4, y, w-8, h, fltk.ALIGN_LEFT)
+		fltk.PopClip()
+	}
+}
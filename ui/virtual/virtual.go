@@ -0,0 +1,54 @@
+// Package virtual computes the layout of a virtualized list: only the rows
+// inside the viewport get a widget from a pool, so the layout cost depends
+// on the height of the viewport and not on the number of items.
+package virtual
+
+// List describes a list of items with a fixed row height stacked below a
+// top margin.
+type List struct {
+	RowHeight int // vertical space of a row including the spacing
+	Gap       int // top margin before the first row
+}
+
+// Range returns the index of the first and the index behind the last item
+// inside a viewport of the given height scrolled to offset.
+func (l List) Range(offset, height, count int) (first, last int) {
+	first = (offset - l.Gap) / l.RowHeight
+	if first < 0 {
+		first = 0
+	}
+	last = (offset+height-l.Gap)/l.RowHeight + 1
+	if last > count {
+		last = count
+	}
+	if first > last {
+		first = last
+	}
+	return first, last
+}
+
+// PoolSize returns the number of row widgets filling a viewport of the
+// given height, with partially visible rows at both ends.
+func (l List) PoolSize(height int) int {
+	return height/l.RowHeight + 2
+}
+
+// ContentHeight returns the height of all rows, covered by the scrollbar.
+func (l List) ContentHeight(count int) int {
+	return count*l.RowHeight + l.Gap
+}
+
+// Layout assigns the visible items to the rows of the pool and calls place
+// with the row, the item index and the offset of the row from the content
+// start. Rows without a visible item get the index -1.
+func (l List) Layout(offset, height, count, rows int, place func(row, index, y int)) {
+	first, last := l.Range(offset, height, count)
+	for row := range rows {
+		index := first + row
+		if index >= last {
+			place(row, -1, 0)
+			continue
+		}
+		place(row, index, l.Gap+index*l.RowHeight)
+	}
+}
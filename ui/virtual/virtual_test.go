@@ -0,0 +1,88 @@
+package virtual
+
+import (
+	"fmt"
+	"testing"
+)
+
+var list = List{RowHeight: 50, Gap: 10}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		name                  string
+		offset, height, count int
+		first, last           int
+	}{
+		{"empty list", 0, 300, 0, 0, 0},
+		{"fewer items than viewport", 0, 300, 3, 0, 3},
+		{"top", 0, 300, 100, 0, 6},
+		{"scrolled into the margin", 5, 300, 100, 0, 6},
+		{"scrolled by rows", 510, 300, 100, 10, 17},
+		{"partial row at the top", 530, 300, 100, 10, 17},
+		{"end of the list", 4710, 300, 100, 94, 100},
+		{"scrolled behind the end", 9000, 300, 100, 100, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last := list.Range(tt.offset, tt.height, tt.count)
+			if first != tt.first || last != tt.last {
+				t.Errorf("Range(%d, %d, %d) = %d, %d, want %d, %d",
+					tt.offset, tt.height, tt.count, first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestLayout(t *testing.T) {
+	rows := list.PoolSize(300)
+	placed := map[int]int{}
+	hidden := 0
+	list.Layout(520, 300, 100, rows, func(row, index, y int) {
+		if index < 0 {
+			hidden++
+			return
+		}
+		if want := list.Gap + index*list.RowHeight; y != want {
+			t.Errorf("row %d: y = %d, want %d", row, y, want)
+		}
+		placed[index] = row
+	})
+	first, last := list.Range(520, 300, 100)
+	if len(placed) != last-first || hidden != rows-(last-first) {
+		t.Errorf("placed %d items and hid %d rows, want %d and %d", len(placed), hidden, last-first, rows-(last-first))
+	}
+	for index := first; index < last; index++ {
+		if _, ok := placed[index]; !ok {
+			t.Errorf("item %d not placed", index)
+		}
+	}
+}
+
+// The cost per layout has to stay the same for any number of items.
+var benchCounts = []int{100, 10_000, 100_000}
+
+func BenchmarkVisibleRange(b *testing.B) {
+	for _, count := range benchCounts {
+		b.Run(fmt.Sprintf("items=%d", count), func(b *testing.B) {
+			content := list.ContentHeight(count)
+			for i := range b.N {
+				list.Range(i*7%content, 600, count)
+			}
+		})
+	}
+}
+
+func BenchmarkLayout(b *testing.B) {
+	for _, count := range benchCounts {
+		b.Run(fmt.Sprintf("items=%d", count), func(b *testing.B) {
+			content := list.ContentHeight(count)
+			rows := list.PoolSize(600)
+			bound := make([]int, rows)
+			for i := range b.N {
+				list.Layout(i*7%content, 600, count, rows, func(row, index, y int) {
+					bound[row] = index
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/convert"
+	"github.com/archeopternix/gofltk-videoconverter/util"
+	"github.com/pwiecz/go-fltk"
+	"gopkg.in/yaml.v2"
+)
+
+// WatchConfig holds the watched folders of the watch folder mode. New
+// video files are converted with the project configuration once they
+// stopped growing.
+type WatchConfig struct {
+	Dirs       []string      // watched directories, subdirectories are not watched
+	ArchiveDir string        // sources are moved here after the conversion, empty keeps them
+	Settle     time.Duration // time the size of a file has to stay unchanged
+	Interval   time.Duration // time between two scans of the directories
+}
+
+func NewWatchConfig() WatchConfig {
+	return WatchConfig{Settle: 30 * time.Second, Interval: 10 * time.Second}
+}
+
+// watchDialog edits the watched folders and starts watching on Start. The
+// toggle button is released when the dialog is cancelled.
+func (a *App) watchDialog(toggle *fltk.ToggleButton) {
+	dialog := fltk.NewWindow(600, 330, "Watch Folders")
+	dialog.SetModal() // Set the window as modal
+	dialog.Begin()
+
+	// temp structure to revert when hit the cancel button
+	cfg := a.watch
+	cfg.Dirs = append([]string(nil), a.watch.Dirs...)
+
+	mainBox := fltk.NewGroup(0, 0, dialog.W(), dialog.H())
+	dialog.Add(mainBox)
+
+	dirList := fltk.NewHoldBrowser(10, 10, 430, 150)
+	for _, dir := range cfg.Dirs {
+		dirList.Add(dir)
+	}
+	addBtn := fltk.NewButton(450, 10, 140, 30, "Add folder")
+	addBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			a.workDir,
+			"*.*",
+			fltk.FileChooser_DIRECTORY,
+			"Choose Watched Directory")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			cfg.Dirs = append(cfg.Dirs, chooser.Selection()[0])
+			dirList.Add(chooser.Selection()[0])
+		}
+	})
+	removeBtn := fltk.NewButton(450, 50, 140, 30, "Remove folder")
+	removeBtn.SetCallback(func() {
+		line := dirList.Value()
+		if line < 1 || line > len(cfg.Dirs) {
+			return
+		}
+		cfg.Dirs = append(cfg.Dirs[:line-1], cfg.Dirs[line:]...)
+		dirList.Remove(line)
+	})
+
+	// Archive for the converted sources
+	archiveBox := fltk.NewBox(fltk.NO_BOX, 10, 170, 430, 30, "")
+	if cfg.ArchiveDir == "" {
+		archiveBox.SetLabel("Sources are kept")
+	} else {
+		archiveBox.SetLabel(cfg.ArchiveDir)
+	}
+	archiveBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	archiveBtn := fltk.NewButton(450, 170, 140, 30, "Archive folder")
+	archiveBtn.SetCallback(func() {
+		chooser := fltk.NewFileChooser(
+			cfg.ArchiveDir,
+			"*.*",
+			fltk.FileChooser_DIRECTORY,
+			"Choose Archive Directory")
+		chooser.Show()
+
+		// Wait for user selection
+		for chooser.Shown() {
+			fltk.Wait()
+		}
+		if len(chooser.Selection()) > 0 {
+			archiveBox.SetLabel(chooser.Selection()[0])
+			cfg.ArchiveDir = chooser.Selection()[0]
+		}
+	})
+	keepBtn := fltk.NewButton(450, 210, 140, 30, "Keep sources")
+	keepBtn.SetCallback(func() {
+		archiveBox.SetLabel("Sources are kept")
+		cfg.ArchiveDir = ""
+	})
+
+	// Seconds a file has to stay unchanged before it is converted
+	settleBox := fltk.NewBox(fltk.NO_BOX, 10, 250, 200, 30, "Unchanged for (seconds)")
+	settleBox.SetAlign(fltk.ALIGN_LEFT | fltk.ALIGN_INSIDE) // Align text left and inside the box
+	settleInput := fltk.NewIntInput(210, 250, 80, 30)
+	settleInput.SetValue(strconv.Itoa(int(cfg.Settle.Seconds())))
+
+	bottomGroup := fltk.NewGroup(0, mainBox.H()-40, mainBox.W(), 30)
+	cancelBtn := fltk.NewButton(bottomGroup.W()/2-110, mainBox.H()-40, 100, 30, "Cancel")
+	startBtn := fltk.NewButton(bottomGroup.W()/2+10, mainBox.H()-40, 100, 30, "Start")
+	cancelBtn.SetCallback(func() {
+		toggle.SetValue(false)
+		dialog.Hide()
+	})
+	startBtn.SetCallback(func() {
+		if len(cfg.Dirs) == 0 {
+			fltk.MessageBox("Watch Folders", "Add a folder to watch")
+			return
+		}
+		seconds, err := strconv.Atoi(settleInput.Value())
+		if err != nil || seconds < 1 {
+			fltk.MessageBox("Watch Folders", "Invalid time: "+settleInput.Value())
+			return
+		}
+		cfg.Settle = time.Duration(seconds) * time.Second
+		a.watch = cfg
+		a.startWatch()
+		dialog.Hide()
+	})
+	bottomGroup.Add(cancelBtn)
+	bottomGroup.Add(startBtn)
+	mainBox.Add(bottomGroup)
+
+	// Finalize the window and display it
+	dialog.End()
+	dialog.Show()
+}
+
+// startWatch watches the folders in the background. New files are added to
+// the list and converted with the project configuration.
+func (a *App) startWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.watchCancel = cancel
+	slog.Info("watch folders", "folders", a.watch.Dirs, "archive", a.watch.ArchiveDir)
+
+	watcher := util.NewWatcher(a.watch.Dirs, a.watch.Settle)
+	go watcher.Run(ctx, a.watch.Interval, func(path string) {
+		fltk.Awake(func() { a.watchFound(path) })
+	})
+}
+
+// stopWatch stops watching the folders. A running conversion continues,
+// queued files are not started.
+func (a *App) stopWatch() {
+	if a.watchCancel == nil {
+		return
+	}
+	slog.Info("watch folders", "msg", "stopped by user")
+	a.watchCancel()
+	a.watchCancel = nil
+	for _, item := range a.watchQueue {
+		item.SetStatus("new")
+	}
+	a.watchQueue = nil
+	a.table.Refresh()
+}
+
+// watchFound adds a file of a watched folder to the list and queues it.
+// Duplicates by content are skipped if the detection is enabled.
+func (a *App) watchFound(path string) {
+	if a.watchCancel == nil {
+		return
+	}
+	// Nobody answers a question in the watch mode, duplicates are skipped
+	if a.sysconfig.DetectDuplicates {
+		if original, ok := findDuplicates([]string{path}, a.lister.Items(), a.projectconfig.OutputDir)[path]; ok {
+			slog.Info("watch folders", "file", path, "msg", "skipped duplicate", "original", original)
+			return
+		}
+	}
+	item := a.lister.AddRow(util.GetInfoFromFileName(path))
+	if item == nil {
+		return
+	}
+	slog.Info("watch folders", "file", path)
+	item.archiveDir = a.watch.ArchiveDir
+	item.SetStatus("waiting")
+	a.table.Refresh()
+	a.watchQueue = append(a.watchQueue, item)
+	a.convertQueue()
+}
+
+// convertQueue starts the conversion of the queued files of the watched
+// folders, unless a conversion is running.
+func (a *App) convertQueue() {
+	if a.cancel != nil || len(a.watchQueue) == 0 {
+		return
+	}
+	items := a.watchQueue
+	a.watchQueue = nil
+	if err := a.convertItems(items); err != nil {
+		slog.Error("watch folders", "error", err)
+	}
+}
+
+// batchFinished starts the files queued by the watched folders during the
+// last batch.
+func (a *App) batchFinished() {
+	if a.watchCancel != nil {
+		a.convertQueue()
+	}
+}
+
+// archiveSource moves the converted source into the archive and returns
+// the status extended by the result and the path of the source.
+func archiveSource(path, dir, status string) (string, string) {
+	moved, err := util.MoveFile(path, dir)
+	if err != nil {
+		slog.Error("archive source", "file", path, "error", err)
+		return status + ", not archived", path
+	}
+	slog.Info("archive source", "file", path, "archive", moved)
+	return status + ", archived", moved
+}
+
+// RunWatch watches the folders without user interface until the context
+// is cancelled. The system and project configuration are read from YAML
+// files, missing fields keep the defaults, empty file names use the
+// defaults. The files are converted one after the other.
+func RunWatch(ctx context.Context, systemFile, projectFile string, cfg WatchConfig) error {
+	sysconfig, prjconfig := NewSystemConfig(".", "."), NewProjectConfig()
+	if err := loadConfig(systemFile, &sysconfig); err != nil {
+		return err
+	}
+	if err := loadConfig(projectFile, &prjconfig); err != nil {
+		return err
+	}
+	util.SetFFprobePath(sysconfig.FFprobePath)
+
+	presets, err := convert.LoadPresets(sysconfig.PresetPath)
+	if err != nil {
+		slog.Error("load presets", "dir", sysconfig.PresetPath, "error", err)
+	}
+	preset, ok := presets.Get(prjconfig.Encoder)
+	if !ok {
+		return fmt.Errorf("unknown encoder %q", prjconfig.Encoder)
+	}
+	if err := prjconfig.Filters.Validate(); err != nil {
+		return err
+	}
+	backend := sysconfig.NewBackend()
+	versions := toolVersions(sysconfig, prjconfig.Script)
+
+	slog.Info("watch folders", "folders", cfg.Dirs, "archive", cfg.ArchiveDir, "encoder", preset.Name)
+	var converted []string // sources converted before, for the duplicate detection
+	watcher := util.NewWatcher(cfg.Dirs, cfg.Settle)
+	watcher.Run(ctx, cfg.Interval, func(path string) {
+		if sysconfig.DetectDuplicates {
+			known := append(util.ListFiles(prjconfig.OutputDir), converted...)
+			if original, ok := util.FindDuplicates([]string{path}, known)[path]; ok {
+				slog.Info("watch folders", "file", path, "msg", "skipped duplicate", "original", original)
+				return
+			}
+		}
+		info := util.GetInfoFromFileName(path)
+		if info == nil || ctx.Err() != nil {
+			return
+		}
+		job := prjconfig.NewJob(&Item{info: info}, preset)
+		err := job.ApplyTargetSize()
+		if err == nil {
+			err = backend.Check(job)
+		}
+		if err != nil {
+			slog.Error("watch folders", "file", info.Name, "backend", backend.Name(), "error", err)
+			return
+		}
+		status, err := convertJob(ctx, backend, sysconfig.FFmpegPath, job, prjconfig.Skip, versions, func(p convert.Progress) {
+			slog.Debug("watch folders", "file", info.Name, "stage", p.Stage, "percent", p.Percent)
+		})
+		switch {
+		case ctx.Err() != nil:
+			slog.Info("watch folders", "file", info.Name, "msg", "cancelled")
+			return
+		case err != nil:
+			slog.Error("watch folders", "file", info.Name, "backend", backend.Name(), "error", err)
+			return
+		}
+		if job.Scores != nil {
+			slog.Info("watch folders", "file", info.Name, "metrics", job.Scores.String())
+		}
+		if cfg.ArchiveDir != "" {
+			status, path = archiveSource(path, cfg.ArchiveDir, status)
+		}
+		converted = append(converted, path)
+		slog.Info("watch folders", "file", info.Name, "status", status)
+	})
+	return nil
+}
+
+// loadConfig reads the YAML file into the configuration. The fields are
+// named in lower case, like "outputdir" or "ffmpegpath".
+func loadConfig(path string, cfg any) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
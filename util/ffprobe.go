@@ -7,20 +7,67 @@ import (
 	"log/slog"
 	"math"
 	"os/exec"
-	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
 	. "gopkg.in/vansante/go-ffprobe.v2"
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	binPath = "ffprobe.exe"
-)
+// binPath is the ffprobe executable, set from the system configuration.
+// The conversions probe files in the background, so it is guarded.
+var binPath atomic.Value
+
+func init() {
+	binPath.Store(DefaultFFprobePath())
+}
+
+// DefaultFFprobePath returns "ffprobe.exe" on Windows and "ffprobe"
+// elsewhere, searched in PATH.
+func DefaultFFprobePath() string {
+	if runtime.GOOS == "windows" {
+		return "ffprobe.exe"
+	}
+	return "ffprobe"
+}
+
+// FFprobePath returns the ffprobe executable used by FFprobe.
+func FFprobePath() string {
+	return binPath.Load().(string)
+}
+
+// SetFFprobePath sets the ffprobe executable used by FFprobe, empty uses
+// the default.
+func SetFFprobePath(path string) {
+	if path == "" {
+		path = DefaultFFprobePath()
+	}
+	binPath.Store(path)
+}
 
-// FFprobe executes 'ffprobe.exe' and returns a populated ffprobe.ProbeData structure
+// FFprobe executes ffprobe and returns a populated ffprobe.ProbeData structure
 func FFprobe(fileURL string, extraFFProbeOptions ...string) (*ProbeData, error) {
+	jsonData, err := ffprobeJSON(fileURL, extraFFProbeOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &ProbeData{}
+
+	// Unmarshal the struct into JSON
+	err = json.Unmarshal(jsonData, probe)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling JSON: %v\n", err)
+	}
+
+	return probe, nil
+}
+
+// ffprobeJSON executes ffprobe and returns the JSON output
+func ffprobeJSON(fileURL string, extraFFProbeOptions ...string) ([]byte, error) {
 	args := append([]string{
 		"-loglevel", "fatal",
 		"-print_format", "json",
@@ -32,23 +79,14 @@ func FFprobe(fileURL string, extraFFProbeOptions ...string) (*ProbeData, error)
 	// Add the file argument
 	args = append(args, fileURL)
 
-	data := exec.Command(binPath, args...)
+	data := exec.Command(FFprobePath(), args...)
 
 	// Running the command and capturing the combined output (stdout and stderr)
 	jsonData, err := data.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("Conversion: %v Error %s", err, string(jsonData))
 	}
-
-	probe := &ProbeData{}
-
-	// Unmarshal the struct into JSON
-	err = json.Unmarshal(jsonData, probe)
-	if err != nil {
-		return nil, fmt.Errorf("Error marshaling JSON: %v\n", err)
-	}
-
-	return probe, nil
+	return jsonData, nil
 }
 
 // IsVideo returns true is file is a video and optional checks container format
@@ -156,26 +194,6 @@ func ProbeDataYAML(pd *ProbeData) []byte {
 	return str
 }
 
-type Info struct {
-	Name        string
-	FullPath    string
-	FileType    string
-	FileSize    string
-	Duration    string
-	Image       []byte
-	VideoType   string
-	ResolutionX int
-	ResolutionY int
-	FPS         string
-	FieldOrder  string
-}
-
-func (i Info) String() string {
-	jsonData, _ := json.Marshal(i)
-
-	return string(jsonData)
-}
-
 // t.00g.00m.00k.000
 func FormatNumberWithUnit(numberStr string) (string, error) {
 	// Parse the input string to a float
@@ -210,83 +228,22 @@ func FormatNumberWithUnit(numberStr string) (string, error) {
 	return fmt.Sprintf("%s %s", formattedValue, unit), nil
 }
 
-func GetInfoFromFileName(fileURL string) *Info {
-	probeData, err := FFprobe(fileURL)
-	if err != nil {
-		return nil
-	}
-
-	path, _ := filepath.Abs(fileURL)
-
-	info := &Info{FullPath: path, Name: filepath.Base(fileURL)}
-
-	// checks if there is a first video stream
-	if probeData.FirstVideoStream() != nil {
-		info.FileType = probeData.Format.FormatName
-		info.FileSize = probeData.Format.Size
-		info.FileSize, _ = FormatNumberWithUnit(info.FileSize)
-		info.VideoType = probeData.FirstVideoStream().CodecName
-		info.ResolutionX = probeData.FirstVideoStream().Width
-		info.ResolutionY = probeData.FirstVideoStream().Height
-		info.FPS, _ = CalculateDivision(probeData.FirstVideoStream().AvgFrameRate, probeData.FirstVideoStream().FieldOrder)
-		info.Duration, _ = ConvertSecondsToHMS(probeData.FirstVideoStream().Duration)
-		info.FieldOrder = probeData.FirstVideoStream().FieldOrder
-	}
-
-	return info
-
-}
-
+// CalculateDivision formats a frame rate like "30000/1001" as "29.97" with
+// the scan type suffix "p" or "i". Unknown field orders get no suffix.
 func CalculateDivision(input string, fieldorder string) (string, error) {
-	// Split the input string by "/"
-	parts := strings.Split(input, "/")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid input format: %s", input)
-	}
-
-	// Parse the numerator and denominator
-	numerator, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	rate, err := mediatime.ParseFrameRate(input)
 	if err != nil {
-		return "", fmt.Errorf("invalid numerator: %v", err)
-	}
-
-	denominator, err := strconv.Atoi(strings.TrimSpace(parts[1]))
-	if err != nil {
-		return "", fmt.Errorf("invalid denominator: %v", err)
-	}
-
-	// Check for division by zero
-	if denominator == 0 {
-		return "", fmt.Errorf("division by zero is not allowed")
+		return "", err
 	}
-
-	// Perform the division
-	result := float64(numerator) / float64(denominator)
-
-	ret := fmt.Sprintf("%.2f", result)
-	if strings.HasSuffix(ret, ".00") {
-		ret, _ = strings.CutSuffix(ret, ".00")
-	}
-
-	if fieldorder == "progressive" {
-		return fmt.Sprint(ret, "p"), nil
-	}
-	// Return the result as a string
-	return fmt.Sprint(ret, "i"), nil
+	return rate.Label(mediatime.ParseFieldOrder(fieldorder)), nil
 }
 
+// ConvertSecondsToHMS formats seconds like "83.52" as "00:01:24", rounded
+// to whole seconds.
 func ConvertSecondsToHMS(secondsStr string) (string, error) {
-	// Parse the input string to a float
-	seconds, err := strconv.ParseFloat(strings.TrimSpace(secondsStr), 64)
+	d, err := mediatime.ParseSeconds(secondsStr)
 	if err != nil {
-		return "", fmt.Errorf("invalid seconds string: %v", err)
+		return "", err
 	}
-
-	// Convert seconds into hours, minutes, and seconds
-	hours := int(seconds) / 3600
-	minutes := (int(seconds) % 3600) / 60
-	remainingSeconds := int(seconds) % 60
-
-	// Format the result
-	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, remainingSeconds), nil
+	return mediatime.FormatHMS(d), nil
 }
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// fingerprintChunk is the size of the parts of a file hashed for the
+// fingerprint.
+const fingerprintChunk = 1 << 20
+
+// Fingerprint identifies a file by its content, independent of the name and
+// the directory. Copies of a file have the same fingerprint.
+type Fingerprint struct {
+	Size int64  // file size in bytes
+	Hash string // SHA-256 of the first, middle and last MiB, hex encoded
+}
+
+// String returns the fingerprint as "size:hash".
+func (f Fingerprint) String() string {
+	return fmt.Sprintf("%d:%s", f.Size, f.Hash)
+}
+
+// FingerprintFile returns the fingerprint of the file. Only the beginning,
+// the middle and the end of the file are read, small files completely.
+func FingerprintFile(path string) (Fingerprint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	size := stat.Size()
+	h := sha256.New()
+	if size <= 3*fingerprintChunk {
+		if _, err := io.Copy(h, file); err != nil {
+			return Fingerprint{}, err
+		}
+	} else {
+		for _, offset := range []int64{0, size/2 - fingerprintChunk/2, size - fingerprintChunk} {
+			if _, err := io.Copy(h, io.NewSectionReader(file, offset, fingerprintChunk)); err != nil {
+				return Fingerprint{}, err
+			}
+		}
+	}
+	return Fingerprint{Size: size, Hash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// FindDuplicates returns the files of paths with the same content as a
+// known file or an earlier file of paths, mapped to that file. Only files
+// of the same size are hashed. Unreadable files are logged and skipped.
+func FindDuplicates(paths, known []string) map[string]string {
+	type file struct {
+		path string
+		size int64
+		new  bool
+	}
+	var files []file
+	bySize := map[int64]int{}
+	seen := map[string]bool{}
+	add := func(path string, new bool) {
+		// the same file is no duplicate
+		key := filepath.Clean(path)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		stat, err := os.Stat(path)
+		if err != nil || !stat.Mode().IsRegular() {
+			return
+		}
+		files = append(files, file{path: path, size: stat.Size(), new: new})
+		bySize[stat.Size()]++
+	}
+	for _, path := range known {
+		add(path, false)
+	}
+	for _, path := range paths {
+		add(path, true)
+	}
+
+	duplicates := map[string]string{}
+	first := map[Fingerprint]string{}
+	for _, f := range files {
+		if bySize[f.size] < 2 {
+			continue
+		}
+		fp, err := FingerprintFile(f.path)
+		if err != nil {
+			slog.Error("fingerprint", "file", f.path, "error", err)
+			continue
+		}
+		if original, ok := first[fp]; ok {
+			if f.new {
+				duplicates[f.path] = original
+			}
+			continue
+		}
+		first[fp] = f.path
+	}
+	return duplicates
+}
+
+// ListFiles returns the regular files of the directory, subdirectories are
+// not read. A missing directory has no files.
+func ListFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() {
+			files = append(files, filepath.ToSlash(filepath.Join(dir, e.Name())))
+		}
+	}
+	return files
+}
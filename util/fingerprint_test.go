@@ -0,0 +1,121 @@
+package util
+
+import (
+	"bytes"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes the content into the file of the directory and returns
+// its path.
+func writeFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFingerprintFile(t *testing.T) {
+	dir := t.TempDir()
+	large := bytes.Repeat([]byte("0123456789abcdef"), 4*fingerprintChunk/16)
+	// the byte at 1.2 MiB lies between the first and the middle chunk
+	unread := bytes.Clone(large)
+	unread[fingerprintChunk*6/5] ^= 0xff
+	middle := bytes.Clone(large)
+	middle[len(middle)/2] ^= 0xff
+
+	tests := []struct {
+		name     string
+		a, b     []byte
+		wantSame bool
+	}{
+		{"copy", []byte("capture"), []byte("capture"), true},
+		{"same size", []byte("capture"), []byte("Capture"), false},
+		{"empty", nil, nil, true},
+		{"large copy", large, bytes.Clone(large), true},
+		{"large, change in the middle", large, middle, false},
+		{"large, change between the chunks", large, unread, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := FingerprintFile(writeFile(t, dir, "a", tt.a))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := FingerprintFile(writeFile(t, dir, "b", tt.b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Size != int64(len(tt.a)) {
+				t.Errorf("Size = %d, want %d", a.Size, len(tt.a))
+			}
+			if (a == b) != tt.wantSame {
+				t.Errorf("fingerprints %s and %s, want same %v", a, b, tt.wantSame)
+			}
+		})
+	}
+
+	if _, err := FingerprintFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("missing file has a fingerprint")
+	}
+	if got := (Fingerprint{Size: 7, Hash: "ab"}).String(); got != "7:ab" {
+		t.Errorf("String() = %q, want 7:ab", got)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "out"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	capture := writeFile(t, dir, "capture.avi", []byte("capture 1"))
+	copied := writeFile(t, dir, "copy of capture.avi", []byte("capture 1"))
+	other := writeFile(t, dir, "other.avi", []byte("capture 2"))
+	single := writeFile(t, dir, "single.avi", []byte("short"))
+	output := writeFile(t, dir, "out/capture.avi", []byte("capture 1"))
+	missing := filepath.Join(dir, "missing.avi")
+
+	tests := []struct {
+		name         string
+		paths, known []string
+		want         map[string]string
+	}{
+		{"nothing", nil, nil, map[string]string{}},
+		{"unique files", []string{capture, other, single}, nil, map[string]string{}},
+		{"copy in the paths", []string{capture, copied, other}, nil, map[string]string{copied: capture}},
+		{"copy of a known file", []string{copied}, []string{capture}, map[string]string{copied: capture}},
+		{"known duplicates not reported", []string{other}, []string{capture, output}, map[string]string{}},
+		{"same path is not a duplicate", []string{capture}, []string{capture}, map[string]string{}},
+		{"same path written differently", []string{filepath.Join(dir, ".", "capture.avi")}, []string{capture}, map[string]string{}},
+		{"same path twice", []string{capture, capture}, nil, map[string]string{}},
+		{"first known file", []string{copied}, []string{output, capture}, map[string]string{copied: output}},
+		{"missing files", []string{missing, copied}, []string{missing, capture}, map[string]string{copied: capture}},
+		{"directories", []string{filepath.Join(dir, "out")}, []string{dir}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FindDuplicates(tt.paths, tt.known); !maps.Equal(got, tt.want) {
+				t.Errorf("FindDuplicates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.avi", nil)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "sub/b.avi", nil)
+	if got := ListFiles(dir); len(got) != 1 || got[0] != filepath.ToSlash(a) {
+		t.Errorf("ListFiles() = %q, want %q", got, a)
+	}
+	if got := ListFiles(filepath.Join(dir, "missing")); got != nil {
+		t.Errorf("ListFiles(missing) = %q", got)
+	}
+}
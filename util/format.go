@@ -0,0 +1,129 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+)
+
+// Presentation helpers turning the typed values of MediaInfo into text for
+// the user interface.
+
+// FormatSize returns a byte size like "1.25 GB".
+func FormatSize(size int64) string {
+	s, err := FormatNumberWithUnit(strconv.FormatInt(size, 10))
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// FormatBitRate returns a bit rate like "8.50 Mbit/s".
+func FormatBitRate(bps int64) string {
+	switch {
+	case bps <= 0:
+		return ""
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbit/s", float64(bps)/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.0f kbit/s", float64(bps)/1e3)
+	}
+	return fmt.Sprintf("%d bit/s", bps)
+}
+
+// FormatResolution returns the resolution of the video stream like "720x576".
+func FormatResolution(v *VideoStream) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", v.Width, v.Height)
+}
+
+// FormatFrameRate returns the frame rate with scan type like "25i" or "29.97p".
+func FormatFrameRate(v *VideoStream) string {
+	if v == nil || v.FrameRate.IsZero() {
+		return ""
+	}
+	return v.FrameRate.Label(v.FieldOrder)
+}
+
+// FormatFieldOrder returns a readable scan type like "interlaced (TFF)".
+func FormatFieldOrder(fo mediatime.FieldOrder) string {
+	switch {
+	case fo == mediatime.FieldOrderProgressive:
+		return "progressive"
+	case fo.TopFieldFirst():
+		return "interlaced (TFF)"
+	case fo.IsInterlaced():
+		return "interlaced (BFF)"
+	}
+	return "unknown"
+}
+
+// FormatPixelFormat returns chroma subsampling and bit depth like "4:2:0 10 bit".
+func FormatPixelFormat(v *VideoStream) string {
+	if v == nil {
+		return ""
+	}
+	if v.ChromaSubsampling == "" {
+		return v.PixelFormat
+	}
+	return fmt.Sprintf("%s %d bit", v.ChromaSubsampling, v.BitDepth)
+}
+
+// FormatColor returns the color metadata like "bt709/bt709/bt709 tv",
+// empty values are shown as "?".
+func FormatColor(c ColorInfo) string {
+	if c == (ColorInfo{}) {
+		return "unspecified"
+	}
+	or := func(s string) string {
+		if s == "" {
+			return "?"
+		}
+		return s
+	}
+	return fmt.Sprintf("%s/%s/%s %s", or(c.Space), or(c.Transfer), or(c.Primaries), or(c.Range))
+}
+
+// FormatAudio returns a short description of the audio stream like
+// "#1 ac3 5.1(side) 48 kHz [deu]".
+func FormatAudio(a *AudioStream) string {
+	parts := []string{fmt.Sprintf("#%d", a.Index), a.Codec}
+	if a.ChannelLayout != "" {
+		parts = append(parts, a.ChannelLayout)
+	} else if a.Channels > 0 {
+		parts = append(parts, fmt.Sprintf("%dch", a.Channels))
+	}
+	if a.SampleRate > 0 {
+		parts = append(parts, strings.TrimSuffix(fmt.Sprintf("%.1f", float64(a.SampleRate)/1000), ".0")+" kHz")
+	}
+	if a.Language != "" {
+		parts = append(parts, "["+a.Language+"]")
+	}
+	if a.Title != "" {
+		parts = append(parts, a.Title)
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatSubtitle returns a short description of the subtitle stream like
+// "#3 dvd_subtitle [eng] forced".
+func FormatSubtitle(s *SubtitleStream) string {
+	parts := []string{fmt.Sprintf("#%d", s.Index), s.Codec}
+	if s.Language != "" {
+		parts = append(parts, "["+s.Language+"]")
+	}
+	if s.Title != "" {
+		parts = append(parts, s.Title)
+	}
+	if s.Forced {
+		parts = append(parts, "forced")
+	}
+	if s.Captions {
+		parts = append(parts, "closed captions")
+	}
+	return strings.Join(parts, " ")
+}
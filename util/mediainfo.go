@@ -0,0 +1,337 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/archeopternix/gofltk-videoconverter/mediatime"
+	. "gopkg.in/vansante/go-ffprobe.v2"
+)
+
+// MediaInfo describes a media file with all of its streams and chapters.
+// All values are stored typed, formatting for display is done by the
+// Format... helpers.
+type MediaInfo struct {
+	Name      string           // file name without directory
+	FullPath  string           // absolute path of the file
+	Container string           // ffprobe format name like "mov,mp4,m4a,3gp,3g2,mj2"
+	Size      int64            // file size in bytes
+	BitRate   int64            // overall bit rate in bit/s
+	Duration  time.Duration    // container duration
+	Video     []VideoStream    // all video streams
+	Audio     []AudioStream    // all audio streams
+	Subtitles []SubtitleStream // all subtitle streams
+	Chapters  []ChapterMark    // chapters in playing order
+}
+
+// VideoStream describes a single video stream.
+type VideoStream struct {
+	Index              int                  // stream index in the container
+	Codec              string               // codec name like "h264" or "dvvideo"
+	Profile            string               // codec profile like "High"
+	Width              int                  // width in pixels
+	Height             int                  // height in pixels
+	FrameRate          mediatime.FrameRate  // exact frame rate
+	FieldOrder         mediatime.FieldOrder // scan type
+	Duration           time.Duration        // stream duration, container duration as fallback
+	StartTime          time.Duration        // start time relative to the container start
+	Frames             int64                // number of frames
+	BitRate            int64                // bit rate in bit/s, 0 if unknown
+	PixelFormat        string               // ffmpeg pixel format like "yuv420p10le"
+	BitDepth           int                  // bits per component
+	ChromaSubsampling  string               // like "4:2:0", "" if unknown
+	SampleAspectRatio  string               // pixel aspect ratio like "16:15"
+	DisplayAspectRatio string               // like "4:3"
+	Color              ColorInfo            // color metadata
+}
+
+// ColorInfo holds the color metadata of a video stream, empty values are
+// unspecified.
+type ColorInfo struct {
+	Range     string // "tv" (limited) or "pc" (full)
+	Space     string // matrix coefficients like "bt709" or "smpte170m"
+	Transfer  string // transfer characteristics like "bt709"
+	Primaries string // color primaries like "bt470bg"
+}
+
+// AudioStream describes a single audio stream.
+type AudioStream struct {
+	Index         int           // stream index in the container
+	Codec         string        // codec name like "ac3" or "pcm_s16le"
+	Channels      int           // number of channels
+	ChannelLayout string        // like "stereo" or "5.1(side)"
+	SampleRate    int           // samples per second
+	BitDepth      int           // bits per sample, 0 for lossy codecs
+	BitRate       int64         // bit rate in bit/s, 0 if unknown
+	Duration      time.Duration // stream duration
+	StartTime     time.Duration // start time relative to the container start
+	Language      string        // ISO 639 language tag
+	Title         string        // stream title tag
+	Default       bool          // default track of the container
+}
+
+// SubtitleStream describes a single subtitle or caption stream.
+type SubtitleStream struct {
+	Index    int    // stream index in the container
+	Codec    string // codec name like "dvd_subtitle", "eia_608" or "subrip"
+	Language string // ISO 639 language tag
+	Title    string // stream title tag
+	Default  bool   // default track of the container
+	Forced   bool   // forced subtitles
+	Captions bool   // EIA-608 closed captions embedded in the video stream Index
+}
+
+// Bitmap returns true for subtitles stored as images like DVD or Blu-ray
+// subtitles, which can not be converted to text.
+func (s *SubtitleStream) Bitmap() bool {
+	switch s.Codec {
+	case "dvd_subtitle", "hdmv_pgs_subtitle", "dvb_subtitle", "xsub":
+		return true
+	}
+	return false
+}
+
+// ChapterMark is a chapter of the media file.
+type ChapterMark struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+// ProbeMediaInfo runs ffprobe on the file and returns its media info.
+func ProbeMediaInfo(fileURL string) (*MediaInfo, error) {
+	jsonData, err := ffprobeJSON(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	probeData := &ProbeData{}
+	if err := json.Unmarshal(jsonData, probeData); err != nil {
+		return nil, fmt.Errorf("parse ffprobe data of %s: %w", fileURL, err)
+	}
+
+	m := NewMediaInfo(fileURL, probeData)
+	m.addClosedCaptions(jsonData)
+	return m, nil
+}
+
+// addClosedCaptions lists the EIA-608 captions of MPEG-2 and H.264 video
+// streams as subtitle streams. ffprobe reports them as "closed_captions"
+// of the video stream, which is missing in ProbeData.
+func (m *MediaInfo) addClosedCaptions(jsonData []byte) {
+	var probe struct {
+		Streams []struct {
+			Index          int    `json:"index"`
+			CodecType      string `json:"codec_type"`
+			ClosedCaptions int    `json:"closed_captions"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(jsonData, &probe); err != nil {
+		return
+	}
+	for _, s := range probe.Streams {
+		if s.CodecType == string(StreamVideo) && s.ClosedCaptions != 0 {
+			m.Subtitles = append(m.Subtitles, SubtitleStream{Index: s.Index, Codec: "eia_608", Captions: true})
+		}
+	}
+}
+
+// GetInfoFromFileName returns the media info of the file or nil when the
+// file can not be probed.
+func GetInfoFromFileName(fileURL string) *MediaInfo {
+	info, err := ProbeMediaInfo(fileURL)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// NewMediaInfo converts ffprobe data of the file into a MediaInfo.
+func NewMediaInfo(fileURL string, probeData *ProbeData) *MediaInfo {
+	path, _ := filepath.Abs(fileURL)
+	m := &MediaInfo{FullPath: path, Name: filepath.Base(fileURL)}
+
+	if f := probeData.Format; f != nil {
+		m.Container = f.FormatName
+		m.Size, _ = strconv.ParseInt(f.Size, 10, 64)
+		m.BitRate, _ = strconv.ParseInt(f.BitRate, 10, 64)
+		m.Duration = f.Duration()
+	}
+
+	for _, s := range probeData.Streams {
+		if s == nil {
+			continue
+		}
+		switch s.CodecType {
+		case string(StreamVideo):
+			// cover art in MP4 and MKV files is a video stream as well
+			if s.Disposition.AttachedPic != 0 {
+				continue
+			}
+			m.Video = append(m.Video, newVideoStream(s, m.Duration))
+		case string(StreamAudio):
+			m.Audio = append(m.Audio, newAudioStream(s))
+		case string(StreamSubtitle):
+			m.Subtitles = append(m.Subtitles, newSubtitleStream(s))
+		}
+	}
+
+	for _, c := range probeData.Chapters {
+		if c == nil {
+			continue
+		}
+		m.Chapters = append(m.Chapters, ChapterMark{Start: c.StartTime(), End: c.EndTime(), Title: c.Title()})
+	}
+
+	return m
+}
+
+// FirstVideo returns the first video stream or nil.
+func (m *MediaInfo) FirstVideo() *VideoStream {
+	if len(m.Video) == 0 {
+		return nil
+	}
+	return &m.Video[0]
+}
+
+// Length returns the playing time of the first video stream, or the
+// container duration if there is no video stream.
+func (m *MediaInfo) Length() time.Duration {
+	if v := m.FirstVideo(); v != nil && v.Duration > 0 {
+		return v.Duration
+	}
+	return m.Duration
+}
+
+func (m MediaInfo) String() string {
+	jsonData, _ := json.Marshal(m)
+
+	return string(jsonData)
+}
+
+func newVideoStream(s *Stream, container time.Duration) VideoStream {
+	v := VideoStream{
+		Index:              s.Index,
+		Codec:              s.CodecName,
+		Profile:            s.Profile,
+		Width:              s.Width,
+		Height:             s.Height,
+		FieldOrder:         mediatime.ParseFieldOrder(s.FieldOrder),
+		PixelFormat:        s.PixFmt,
+		SampleAspectRatio:  s.SampleAspectRatio,
+		DisplayAspectRatio: s.DisplayAspectRatio,
+		Color: ColorInfo{
+			Range:     unspecified(s.ColorRange),
+			Space:     unspecified(s.ColorSpace),
+			Transfer:  unspecified(s.ColorTransfer),
+			Primaries: unspecified(s.ColorPrimaries),
+		},
+	}
+	v.BitRate, _ = strconv.ParseInt(s.BitRate, 10, 64)
+
+	// The average frame rate is preferred, the real base frame rate is used
+	// when the average is unknown.
+	rate, err := mediatime.ParseFrameRate(s.AvgFrameRate)
+	if err != nil {
+		rate, _ = mediatime.ParseFrameRate(s.RFrameRate)
+	}
+	v.FrameRate = rate
+
+	// A missing stream duration falls back to the container duration
+	var fallback string
+	if container > 0 {
+		fallback = strconv.FormatFloat(container.Seconds(), 'f', -1, 64)
+	}
+	v.Duration, _ = mediatime.FirstDuration(s.Duration, fallback)
+	v.StartTime, _ = mediatime.ParseSeconds(s.StartTime)
+
+	v.Frames, err = strconv.ParseInt(s.NbFrames, 10, 64)
+	if err != nil || v.Frames <= 0 {
+		v.Frames = rate.Frames(v.Duration)
+	}
+
+	v.ChromaSubsampling, v.BitDepth = pixelFormatInfo(s.PixFmt)
+	if bits, err := strconv.Atoi(s.BitsPerRawSample); err == nil && bits > 0 {
+		v.BitDepth = bits
+	}
+	return v
+}
+
+func newAudioStream(s *Stream) AudioStream {
+	a := AudioStream{
+		Index:         s.Index,
+		Codec:         s.CodecName,
+		Channels:      s.Channels,
+		ChannelLayout: s.ChannelLayout,
+		BitDepth:      s.BitsPerSample,
+		Default:       s.Disposition.Default != 0,
+	}
+	a.SampleRate, _ = strconv.Atoi(s.SampleRate)
+	a.BitRate, _ = strconv.ParseInt(s.BitRate, 10, 64)
+	a.Duration, _ = mediatime.ParseSeconds(s.Duration)
+	a.StartTime, _ = mediatime.ParseSeconds(s.StartTime)
+	if bits, err := strconv.Atoi(s.BitsPerRawSample); err == nil && bits > 0 {
+		a.BitDepth = bits
+	}
+	a.Language, _ = s.TagList.GetString("language")
+	a.Title, _ = s.TagList.GetString("title")
+	return a
+}
+
+func newSubtitleStream(s *Stream) SubtitleStream {
+	sub := SubtitleStream{
+		Index:   s.Index,
+		Codec:   s.CodecName,
+		Default: s.Disposition.Default != 0,
+		Forced:  s.Disposition.Forced != 0,
+	}
+	sub.Language, _ = s.TagList.GetString("language")
+	sub.Title, _ = s.TagList.GetString("title")
+	return sub
+}
+
+// unspecified returns "" for the ffprobe values "unknown" and "unspecified".
+func unspecified(s string) string {
+	if s == "unknown" || s == "unspecified" {
+		return ""
+	}
+	return s
+}
+
+// pixelFormatInfo derives chroma subsampling and bit depth from an ffmpeg
+// pixel format like "yuv420p", "yuv422p10le" or "yuv411p" (NTSC DV).
+func pixelFormatInfo(pixFmt string) (chroma string, depth int) {
+	name := strings.TrimSuffix(strings.TrimSuffix(pixFmt, "le"), "be")
+	depth = 8
+	for _, bits := range []int{16, 14, 12, 10, 9} {
+		if strings.HasSuffix(name, strconv.Itoa(bits)) {
+			depth = bits
+			break
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(name, "yuv420"), strings.HasPrefix(name, "yuvj420"),
+		strings.HasPrefix(name, "yuva420"), strings.HasPrefix(name, "nv12"),
+		strings.HasPrefix(name, "nv21"), strings.HasPrefix(name, "p010"):
+		chroma = "4:2:0"
+	case strings.HasPrefix(name, "yuv422"), strings.HasPrefix(name, "yuvj422"),
+		strings.HasPrefix(name, "yuva422"), name == "yuyv422", name == "uyvy422",
+		strings.HasPrefix(name, "nv16"):
+		chroma = "4:2:2"
+	case strings.HasPrefix(name, "yuv444"), strings.HasPrefix(name, "yuvj444"),
+		strings.HasPrefix(name, "yuva444"), strings.HasPrefix(name, "gbr"),
+		strings.HasPrefix(name, "rgb"), strings.HasPrefix(name, "bgr"):
+		chroma = "4:4:4"
+	case strings.HasPrefix(name, "yuv411"), name == "uyyvyy411":
+		chroma = "4:1:1"
+	case strings.HasPrefix(name, "yuv410"):
+		chroma = "4:1:0"
+	case strings.HasPrefix(name, "gray"):
+		chroma = "4:0:0"
+	}
+	return chroma, depth
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseDroppedText splits the text of a drag and drop or paste event into
+// file paths. The text may be a text/uri-list with file:// URIs or a list of
+// plain paths separated by newlines.
+func ParseDroppedText(text string) []string {
+	var paths []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		// empty lines and comments of text/uri-list
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "file:") {
+			path, err := FileURIToPath(line)
+			if err != nil {
+				slog.Debug("invalid file URI", "uri", line, "msg", err)
+				continue
+			}
+			line = path
+		}
+		paths = append(paths, line)
+	}
+	return paths
+}
+
+// FileURIToPath converts a file:// URI into a local path. Percent encoded
+// characters are decoded, on Windows the leading slash before the drive
+// letter is removed ("file:///C:/video.avi" -> "C:/video.avi").
+func FileURIToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI: %s", uri)
+	}
+
+	path := u.Path
+	if u.Host != "" && u.Host != "localhost" {
+		// file://server/share/video.avi is a network share
+		path = "//" + u.Host + path
+	}
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
+
+// CollectVideoFiles returns all video files of the given paths. Folders are
+// searched recursively and every file is validated with IsVideo, optionally
+// restricted to the given container formats.
+func CollectVideoFiles(paths []string, container ...string) []string {
+	var videofiles []string
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			slog.Debug("file not accessible", "file", path, "msg", err)
+			continue
+		}
+
+		if !stat.IsDir() {
+			if IsVideo(path, container...) {
+				videofiles = append(videofiles, filepath.ToSlash(path))
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				slog.Debug("folder not accessible", "folder", file, "msg", err)
+				return nil
+			}
+			if d.Type().IsRegular() && IsVideo(file, container...) {
+				videofiles = append(videofiles, filepath.ToSlash(file))
+			}
+			return nil
+		})
+		if err != nil {
+			slog.Error("read folder", "folder", path, "msg", err)
+		}
+	}
+	return videofiles
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileURIToPath(t *testing.T) {
+	tests := []struct {
+		uri     string
+		want    string
+		wantErr bool
+	}{
+		{"file:///home/user/video.avi", "/home/user/video.avi", false},
+		{"file:/home/user/video.avi", "/home/user/video.avi", false},
+		{"file://localhost/home/user/video.avi", "/home/user/video.avi", false},
+		{"file:///home/user/my%20video.avi", "/home/user/my video.avi", false},
+		{"file:///home/user/Gr%C3%BC%C3%9Fe.avi", "/home/user/Grüße.avi", false},
+		{"file:///home/user/%E5%8B%95%E7%94%BB.avi", "/home/user/動画.avi", false},
+		{"file:///home/user/100%25%20%231.avi", "/home/user/100% #1.avi", false},
+		{"file:///home/user/it's.avi", "/home/user/it's.avi", false},
+		{"file:///C:/Videos/video.avi", "C:/Videos/video.avi", false},
+		{"file:///c:/Videos/my%20video.avi", "c:/Videos/my video.avi", false},
+		{"file:///C%3A/Videos/video.avi", "C:/Videos/video.avi", false},
+		{"file://nas/share/video.avi", "//nas/share/video.avi", false},
+		{"http://example.com/video.avi", "", true},
+		{"file:///home/user/%zz.avi", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := FileURIToPath(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FileURIToPath() error = %v, want error %v", err, tt.wantErr)
+			}
+			if want := filepath.FromSlash(tt.want); got != want {
+				t.Errorf("FileURIToPath() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestParseDroppedText(t *testing.T) {
+	// paths of URIs get the separator of the system, plain paths are kept
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"plain path", "/home/user/video.avi", []string{"/home/user/video.avi"}},
+		{"plain paths with spaces", "/home/user/my video.avi\n/home/user/b.avi\n",
+			[]string{"/home/user/my video.avi", "/home/user/b.avi"}},
+		{"uri list", "# comment\r\nfile:///home/user/a%20b.avi\r\n\r\nfile:///C:/Videos/c.avi\r\n",
+			[]string{filepath.FromSlash("/home/user/a b.avi"), filepath.FromSlash("C:/Videos/c.avi")}},
+		{"invalid uri skipped", "file:///home/user/%zz.avi\nfile:///home/user/ok.avi",
+			[]string{filepath.FromSlash("/home/user/ok.avi")}},
+		{"blank lines", "\n  \n /home/user/video.avi \n", []string{"/home/user/video.avi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseDroppedText(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("ParseDroppedText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watcher polls directories for new video files. Polling also works on
+// network shares, which deliver no file system notifications. Only the
+// files directly in the directories are watched.
+type Watcher struct {
+	Dirs      []string      // watched directories
+	Settle    time.Duration // time the size of a file has to stay unchanged
+	Container []string      // accepted container formats, empty for all, see IsVideo
+
+	files map[string]*watchedFile
+}
+
+// watchedFile is the state of a file seen in a watched directory.
+type watchedFile struct {
+	size   int64
+	mod    time.Time
+	stable time.Time // time of the last change of size or modification time
+	done   bool      // returned or rejected, not checked again
+}
+
+// NewWatcher returns a watcher of the directories reporting files which
+// stopped growing for the settle time.
+func NewWatcher(dirs []string, settle time.Duration) *Watcher {
+	return &Watcher{Dirs: dirs, Settle: settle, files: map[string]*watchedFile{}}
+}
+
+// Poll scans the directories and returns the video files, which didn't
+// change for the settle time. Each file is returned once, files which are
+// no videos are skipped. Files which disappeared are forgotten.
+func (w *Watcher) Poll(now time.Time) []string {
+	seen := map[string]bool{}
+	var ready []string
+	for _, dir := range w.Dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			slog.Error("watch folder", "folder", dir, "msg", err)
+			continue
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			path := filepath.ToSlash(filepath.Join(dir, e.Name()))
+			seen[path] = true
+			stat, err := e.Info()
+			if err != nil {
+				continue
+			}
+
+			f, ok := w.files[path]
+			if !ok || f.size != stat.Size() || !f.mod.Equal(stat.ModTime()) {
+				w.files[path] = &watchedFile{size: stat.Size(), mod: stat.ModTime(), stable: now}
+				continue
+			}
+			if f.done || now.Sub(f.stable) < w.Settle {
+				continue
+			}
+			f.done = true
+			if IsVideo(path, w.Container...) {
+				ready = append(ready, path)
+			}
+		}
+	}
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+	return ready
+}
+
+// Run polls the directories every interval until the context is cancelled
+// and calls found for each new video file.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration, found func(path string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, path := range w.Poll(time.Now()) {
+			found(path)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MoveFile moves the file into the directory and returns the new path. An
+// existing file of the same name is not overwritten. Files on another
+// file system are copied and removed.
+func MoveFile(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(target); err == nil {
+		return "", fmt.Errorf("move %s: %s exists", path, target)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := os.Rename(path, target); err == nil {
+		return target, nil
+	}
+
+	if err := copyFile(path, target); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("move %s: %w", path, err)
+	}
+	return target, os.Remove(path)
+}
+
+// copyFile copies the file to the new file target.
+func copyFile(path, target string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}